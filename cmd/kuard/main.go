@@ -20,7 +20,6 @@ import (
 	"context"
 	"encoding/json"
 	"log/slog"
-	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -30,12 +29,14 @@ import (
 	"github.com/spf13/viper"
 
 	"github.com/kubernetes-up-and-running/kuard/pkg/app"
+	"github.com/kubernetes-up-and-running/kuard/pkg/redact"
+	"github.com/kubernetes-up-and-running/kuard/pkg/requestid"
 	"github.com/kubernetes-up-and-running/kuard/pkg/version"
 )
 
 func main() {
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+	// Attach request IDs to records logged with a request context.
+	slog.SetDefault(slog.New(requestid.NewLogHandler(slog.NewTextHandler(os.Stderr, nil))))
 
 	application := app.NewApp()
 	v := viper.GetViper()
@@ -45,31 +46,44 @@ func main() {
 	slog.Info("starting kuard", "version", version.VERSION)
 	slog.Warn("this server may expose sensitive and secret information; be careful")
 
+	if err := application.LoadConfig(v); err != nil {
+		slog.Error("invalid config", "error", err)
+		os.Exit(1)
+	}
+	if err := application.CheckRoutes(); err != nil {
+		slog.Error("conflicting route registrations", "error", err)
+		os.Exit(1)
+	}
 	dumpConfig(v)
-	application.LoadConfig(v)
-
-	server := application.BuildServer()
-	go func() {
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			slog.Error("http server error", "error", err)
-		}
-	}()
-	slog.Info("serving http", "addr", server.Addr)
-
-	<-ctx.Done()
-	slog.Info("shutdown signal received")
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	application.WatchConfig(v)
+
+	shutdownTracing, err := application.StartTracing(context.Background())
+	if err != nil {
+		slog.Error("tracing disabled", "error", err)
+		shutdownTracing = func(context.Context) error { return nil }
+	}
+
+	sigs := make(chan os.Signal, 2)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	ctx := application.HandleSignals(sigs)
+
+	exitCode := 0
+	if err := application.Run(ctx); err != nil {
+		slog.Error("server error", "error", err)
+		exitCode = 1
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		slog.Error("graceful shutdown failed", "error", err)
-		_ = server.Close()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("trace flush failed", "error", err)
 	}
 	slog.Info("server exited")
-	os.Exit(0)
+	os.Exit(exitCode)
 }
 
 func dumpConfig(v *viper.Viper) {
-	b, err := json.MarshalIndent(v.AllSettings(), "", "  ")
+	b, err := json.MarshalIndent(redact.Map(v.AllSettings()), "", "  ")
 	if err != nil {
 		slog.Error("could not dump config", "error", err)
 		return
@@ -18,6 +18,7 @@ package apiutils
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"time"
 )
@@ -40,3 +41,30 @@ func NoCache(w http.ResponseWriter) {
 		w.Header().Set(k, v)
 	}
 }
+
+// Duration is a time.Duration that JSON encodes as a string such as "150ms"
+// rather than a count of nanoseconds. Numbers are still accepted as
+// nanoseconds when decoding.
+type Duration time.Duration
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var ns int64
+		if err := json.Unmarshal(b, &ns); err != nil {
+			return fmt.Errorf("duration %s: want a string such as \"150ms\"", b)
+		}
+		*d = Duration(ns)
+		return nil
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
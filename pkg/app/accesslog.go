@@ -0,0 +1,108 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package app
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/felixge/httpsnoop"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/requestid"
+	"github.com/kubernetes-up-and-running/kuard/pkg/tracing"
+)
+
+// Access log formats accepted by --access-log.
+const (
+	accessLogJSON   = "json"
+	accessLogLogfmt = "logfmt"
+	accessLogCLF    = "clf"
+	accessLogNone   = "none"
+)
+
+// accessLogger writes one line per completed request using the metrics
+// captured by promMiddleware.
+type accessLogger struct {
+	format string
+	log    *slog.Logger // json and logfmt
+
+	mu  sync.Mutex // clf
+	out io.Writer
+}
+
+func newAccessLogger(format string, out io.Writer) (*accessLogger, error) {
+	al := &accessLogger{format: format, out: out}
+	switch format {
+	case accessLogJSON:
+		al.log = slog.New(slog.NewJSONHandler(out, nil))
+	case accessLogLogfmt:
+		al.log = slog.New(slog.NewTextHandler(out, nil))
+	case accessLogCLF:
+	case accessLogNone, "":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", format)
+	}
+	return al, nil
+}
+
+func (al *accessLogger) record(r *http.Request, pattern string, m httpsnoop.Metrics) {
+	if al == nil {
+		return
+	}
+	if al.format == accessLogCLF {
+		al.writeCLF(r, m)
+		return
+	}
+	al.log.LogAttrs(r.Context(), slog.LevelInfo, "access",
+		slog.String("remote", r.RemoteAddr),
+		slog.String("method", r.Method),
+		slog.String("uri", r.URL.RequestURI()),
+		slog.String("route", pattern),
+		slog.String("proto", r.Proto),
+		slog.Int("status", m.Code),
+		slog.Int64("bytes", m.Written),
+		slog.Float64("duration_seconds", m.Duration.Seconds()),
+		slog.String("user_agent", r.UserAgent()),
+		slog.String("request_id", requestid.FromContext(r.Context())),
+		slog.String("trace_id", tracing.TraceID(r.Context())),
+	)
+}
+
+// writeCLF writes an NCSA Common Log Format line:
+//
+//	host ident authuser [date] "request line" status bytes
+func (al *accessLogger) writeCLF(r *http.Request, m httpsnoop.Metrics) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	user := "-"
+	if u, _, ok := r.BasicAuth(); ok && u != "" {
+		user = u
+	}
+	ts := time.Now().Add(-m.Duration).Format("02/Jan/2006:15:04:05 -0700")
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+	fmt.Fprintf(al.out, "%s - %s [%s] \"%s %s %s\" %d %d\n",
+		host, user, ts, r.Method, r.URL.RequestURI(), r.Proto, m.Code, m.Written)
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/requestid"
+)
+
+func TestAccessLogFormats(t *testing.T) {
+	sr := NewSimpleRouter()
+	sr.GET("/queues/:name", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	}))
+
+	serve := func(format string) string {
+		var buf bytes.Buffer
+		al, err := newAccessLogger(format, &buf)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/queues/work?x=1", nil)
+		req.Header.Set(requestid.Header, "rid-1")
+		requestid.Middleware(promMiddleware(sr, al)).ServeHTTP(httptest.NewRecorder(), req)
+		return buf.String()
+	}
+
+	var rec map[string]any
+	if err := json.Unmarshal([]byte(serve(accessLogJSON)), &rec); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if rec["status"] != float64(418) || rec["bytes"] != float64(5) || rec["route"] != "/queues/:name" || rec["request_id"] != "rid-1" {
+		t.Fatalf("unexpected json record %v", rec)
+	}
+
+	if line := serve(accessLogLogfmt); !regexp.MustCompile(`status=418 bytes=5 .*request_id=rid-1`).MatchString(line) {
+		t.Fatalf("unexpected logfmt line %q", line)
+	}
+
+	clf := regexp.MustCompile(`^192\.0\.2\.1 - - \[[^\]]+\] "GET /queues/work\?x=1 HTTP/1\.1" 418 5\n$`)
+	if line := serve(accessLogCLF); !clf.MatchString(line) {
+		t.Fatalf("unexpected clf line %q", line)
+	}
+
+	if _, err := newAccessLogger("xml", &bytes.Buffer{}); err == nil {
+		t.Fatalf("expected error for unknown format")
+	}
+}
@@ -16,232 +16,536 @@ limitations under the License.
 package app
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
-	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
-
+	"sync"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/apiutils"
+	"github.com/kubernetes-up-and-running/kuard/pkg/auth"
+	"github.com/kubernetes-up-and-running/kuard/pkg/certs"
+	"github.com/kubernetes-up-and-running/kuard/pkg/cgroup"
+	"github.com/kubernetes-up-and-running/kuard/pkg/chaos"
+	"github.com/kubernetes-up-and-running/kuard/pkg/cpu"
 	"github.com/kubernetes-up-and-running/kuard/pkg/debugprobe"
 	"github.com/kubernetes-up-and-running/kuard/pkg/dnsapi"
 	"github.com/kubernetes-up-and-running/kuard/pkg/env"
+	"github.com/kubernetes-up-and-running/kuard/pkg/faults"
 	"github.com/kubernetes-up-and-running/kuard/pkg/fsapi"
 	"github.com/kubernetes-up-and-running/kuard/pkg/htmlutils"
 	"github.com/kubernetes-up-and-running/kuard/pkg/keygen"
+	"github.com/kubernetes-up-and-running/kuard/pkg/lifecycle"
 	"github.com/kubernetes-up-and-running/kuard/pkg/memory"
 	memqserver "github.com/kubernetes-up-and-running/kuard/pkg/memq/server"
+	"github.com/kubernetes-up-and-running/kuard/pkg/openapi"
+	"github.com/kubernetes-up-and-running/kuard/pkg/podinfo"
+	"github.com/kubernetes-up-and-running/kuard/pkg/requestid"
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
 	"github.com/kubernetes-up-and-running/kuard/pkg/sitedata"
+	"github.com/kubernetes-up-and-running/kuard/pkg/tracing"
 	"github.com/kubernetes-up-and-running/kuard/pkg/version"
 
 	"github.com/felixge/httpsnoop"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 )
 
 func init() {
-	prometheus.MustRegister(requestDuration)
+	prometheus.MustRegister(requestDuration, requestSize, responseSize, requestsInFlight)
 }
 
+// unmatchedRoute is the route label shared by requests no pattern matched, so
+// scanners probing random paths cannot create new time series.
+const unmatchedRoute = "unmatched"
+
 var requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
 	Name:    "request_duration_seconds",
 	Help:    "Time serving HTTP request",
 	Buckets: prometheus.DefBuckets,
 }, []string{"method", "route", "status_code"})
 
-func promMiddleware(h http.Handler) http.Handler {
+var requestSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "request_size_bytes",
+	Help:    "Size of HTTP request bodies",
+	Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+}, []string{"method", "route"})
+
+var responseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "response_size_bytes",
+	Help:    "Size of HTTP response bodies",
+	Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+}, []string{"method", "route"})
+
+var requestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "requests_in_flight",
+	Help: "HTTP requests currently being served",
+})
+
+// promMiddleware records request metrics and hands the captured metrics to
+// the access log, if one is configured.
+func promMiddleware(h http.Handler, al *accessLogger) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		m := httpsnoop.CaptureMetrics(h, w, r)
-		requestDuration.WithLabelValues(r.Method, r.URL.Path, strconv.Itoa(m.Code)).Observe(m.Duration.Seconds())
-	})
-}
+		requestsInFlight.Inc()
+		defer requestsInFlight.Dec()
 
-func loggingMiddleware(handler http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		slog.Info("request", "remote", r.RemoteAddr, "method", r.Method, "url", r.URL.String())
-		handler.ServeHTTP(w, r)
+		ctx, match := route.EnsureMatch(r.Context())
+		m := httpsnoop.CaptureMetrics(h, w, r.WithContext(ctx))
+
+		label := match.Pattern
+		if label == "" {
+			label = unmatchedRoute
+		}
+		requestDuration.WithLabelValues(r.Method, label, strconv.Itoa(m.Code)).Observe(m.Duration.Seconds())
+		requestSize.WithLabelValues(r.Method, label).Observe(float64(max(r.ContentLength, 0)))
+		responseSize.WithLabelValues(r.Method, label).Observe(float64(m.Written))
+		al.record(r, label, m)
 	})
 }
 
 type pageContext struct {
-	URLBase      string   `json:"urlBase"`
-	Hostname     string   `json:"hostname"`
-	Addrs        []string `json:"addrs"`
-	Version      string   `json:"version"`
-	VersionColor string   `json:"versionColor"`
-	RequestDump  string   `json:"requestDump"`
-	RequestProto string   `json:"requestProto"`
-	RequestAddr  string   `json:"requestAddr"`
+	URLBase       string   `json:"urlBase"`
+	Variant       string   `json:"variant,omitempty"`
+	VariantReason string   `json:"variantReason,omitempty"` // path, header, cookie, weighted or default
+	Capabilities  []string `json:"capabilities"`            // optional subsystems that are enabled
+	Hostname      string   `json:"hostname"`
+	Addrs         []string `json:"addrs"`
+	Version       string   `json:"version"`
+	VersionColor  string   `json:"versionColor"`
+	RequestDump   string   `json:"requestDump"`
+	RequestProto  string   `json:"requestProto"`
+	RequestAddr   string   `json:"requestAddr"`
+	RequestID     string   `json:"requestId"`
+	TraceID       string   `json:"traceId,omitempty"`
+	TLS           *tlsInfo `json:"tls,omitempty"`
+
+	Pod *podinfo.Info `json:"pod,omitempty"`
+}
+
+// routeStatus is a single entry in the /routes listing.
+type routeStatus struct {
+	Method    string `json:"method"`
+	Pattern   string `json:"pattern"`
+	Subsystem string `json:"subsystem"`
+	Summary   string `json:"summary,omitempty"`
 }
 
 type App struct {
-	c Config
+	mu sync.Mutex // guards c and the config fields below
+	c  Config
+
+	v          *viper.Viper
+	fs         *pflag.FlagSet
+	overrides  map[string]bool // keys set through PATCH /config
+	settings   []configSetting // as of the last successful load
+	configFile string
 
 	m     *memory.MemoryAPI
+	cpu   *cpu.CPUAPI
 	live  *debugprobe.Probe
 	ready *debugprobe.Probe
 	env   *env.Env
 	dns   *dnsapi.DNSAPI
 	kg    *keygen.KeyGen
 	mq    *memqserver.Server
+	fsa   *fsapi.API
+
+	faults *faults.Injector
+	chaos  *chaos.Chaos
+
+	variants []*variant
+
+	r         *SimpleRouter
+	accessLog *accessLogger
+	certs     *certs.Reloader
+	pod       *podinfo.Source
+}
+
+// handler returns the router wrapped in the standard middleware chain.
+func (k *App) handler() http.Handler {
+	return requestid.Middleware(tracing.Middleware(promMiddleware(k.r, k.accessLog)))
+}
+
+// StartTracing installs the configured trace exporter. The returned function
+// flushes pending spans and should be called on exit.
+func (k *App) StartTracing(ctx context.Context) (func(context.Context) error, error) {
+	return tracing.Setup(ctx, k.c.Tracing)
+}
+
+// CheckRoutes reports duplicate or conflicting route registrations made by
+// NewApp or LoadConfig.
+func (k *App) CheckRoutes() error {
+	return k.r.Err()
+}
 
-	r *SimpleRouter
+func (k *App) serveRoutes(w http.ResponseWriter, r *http.Request) {
+	routes := []routeStatus{}
+	for _, rt := range k.r.Routes() {
+		routes = append(routes, routeStatus{Method: rt.Method, Pattern: rt.Pattern, Subsystem: rt.Subsystem, Summary: rt.Summary})
+	}
+	apiutils.ServeJSON(w, routes)
 }
 
-// BuildServer builds an *http.Server with middleware applied.
-func (k *App) BuildServer() *http.Server {
-	handler := promMiddleware(loggingMiddleware(k.r))
-	return &http.Server{Addr: k.c.ServeAddr, Handler: handler}
+// serveOpenAPI documents the root API once. The copies registered under
+// each variant's prefix are mentioned in the description instead.
+func (k *App) serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	variants := map[string]bool{}
+	var prefixes []string
+	for _, v := range k.variants {
+		variants[v.name] = true
+		prefixes = append(prefixes, v.prefix)
+	}
+	var routes []route.Info
+	for _, rt := range k.r.Routes() {
+		seg, _, _ := strings.Cut(strings.TrimPrefix(rt.Pattern, "/"), "/")
+		if !variants[seg] {
+			routes = append(routes, rt)
+		}
+	}
+	doc := openapi.Build("kuard", version.VERSION, routes)
+	if len(prefixes) > 0 {
+		doc.Info.Description = "Every variant serves the same API under its own prefix: " +
+			strings.Join(prefixes, ", ") + ". For example " + prefixes[0] + "/pageinfo."
+	}
+	apiutils.ServeJSON(w, doc)
 }
 
-func (k *App) getPageContext(r *http.Request, urlBase string) *pageContext {
+func (k *App) getPageContext(r *http.Request, v *variant) *pageContext {
 	c := &pageContext{}
-	c.URLBase = urlBase
+	c.URLBase = v.prefix
+	c.Variant = v.name
+	if s, ok := selectionFrom(r); ok {
+		c.VariantReason = s.reason
+	}
 	c.Hostname, _ = os.Hostname()
 
 	addrs, _ := net.InterfaceAddrs()
 	c.Addrs = []string{}
 	for _, addr := range addrs {
-		// check the address type and if it is not a loopback
-		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-			if ipnet.IP.To4() != nil {
-				c.Addrs = append(c.Addrs, ipnet.IP.String())
-			}
+		// IPv4 and IPv6, skipping loopback and fe80:: link-local addresses
+		// that every interface has.
+		if ipnet, ok := addr.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && !ipnet.IP.IsLinkLocalUnicast() {
+			c.Addrs = append(c.Addrs, ipnet.IP.String())
 		}
 	}
+	if pod := k.pod.Info(); !pod.Empty() {
+		c.Pod = &pod
+	}
 
-	c.Version = version.VERSION
-	c.VersionColor = htmlutils.ColorFromString(version.VERSION)
+	c.Version = v.version
+	c.VersionColor = v.color
 	reqDump, _ := httputil.DumpRequest(r, false)
 	c.RequestDump = strings.TrimSpace(string(reqDump))
 	c.RequestProto = r.Proto
 	c.RequestAddr = r.RemoteAddr
+	c.RequestID = requestid.FromContext(r.Context())
+	c.TraceID = tracing.TraceID(r.Context())
+	c.TLS = getTLSInfo(r.TLS)
 
 	return c
 }
 
 // legacy root template removed
 
-// Exists reports whether the named file or directory exists.
-func fileExists(name string) bool {
-	if _, err := os.Stat(name); err != nil {
-		if os.IsNotExist(err) {
-			return false
+// loadCerts prepares the HTTPS certificate: from TLSDir when present,
+// otherwise self-signed if enabled. It returns nil when HTTPS is disabled.
+func (k *App) loadCerts() *certs.Reloader {
+	cr := certs.NewReloader(k.c.TLSDir)
+	switch {
+	case certs.Exist(k.c.TLSDir):
+		if err := cr.Load(); err != nil {
+			slog.Error("could not load tls certs; skipping https", "dir", k.c.TLSDir, "error", err)
+			return nil
+		}
+	case k.c.TLSSelfSigned:
+		hosts := certs.LocalHosts()
+		c, err := certs.SelfSigned(hosts)
+		if err != nil {
+			slog.Error("could not generate self-signed cert; skipping https", "error", err)
+			return nil
+		}
+		slog.Info("tls certs not found; using self-signed cert", "dir", k.c.TLSDir, "hosts", hosts)
+		cr.SetCertificate(c)
+	default:
+		slog.Warn("tls certs not found; skipping https", "dir", k.c.TLSDir)
+		return nil
+	}
+	return cr
+}
+
+// buildLifecycle creates the lifecycle manager with every listener: HTTP
+// always, and HTTPS when a certificate is available.
+func (k *App) buildLifecycle() *lifecycle.Manager {
+	h := k.handler()
+	m := lifecycle.New(k.c.ShutdownGrace)
+	m.Add(lifecycle.Listener{Name: "http", Server: &http.Server{Addr: k.c.ServeAddr, Handler: h}})
+
+	if k.certs = k.loadCerts(); k.certs != nil {
+		tc := &tls.Config{GetCertificate: k.certs.GetCertificate}
+		if err := clientAuthConfig(tc, k.c.TLSClientCA, k.c.TLSClientAuth); err != nil {
+			// Never fall back to serving without the requested verification.
+			slog.Error("invalid client cert config; skipping https", "ca", k.c.TLSClientCA, "error", err)
+			k.certs = nil
+			return m
 		}
+		if tc.ClientCAs != nil {
+			slog.Info("tls client cert verification enabled", "ca", k.c.TLSClientCA, "mode", tc.ClientAuth.String())
+		}
+		m.Add(lifecycle.Listener{
+			Name:   "https",
+			Server: &http.Server{Addr: k.c.TLSAddr, Handler: h, TLSConfig: tc},
+			TLS:    true,
+		})
 	}
-	return true
+	return m
 }
 
-func (k *App) Run() {
-	r := promMiddleware(loggingMiddleware(k.r))
+// Run serves all listeners until ctx is cancelled. It then drains for the
+// configured delay and shuts the listeners down within the grace period.
+func (k *App) Run(ctx context.Context) error {
+	m := k.buildLifecycle()
 
-	certFile := filepath.Join(k.c.TLSDir, "kuard.crt")
-	keyFile := filepath.Join(k.c.TLSDir, "kuard.key")
-	if fileExists(certFile) && fileExists(keyFile) {
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if k.certs != nil {
 		go func() {
-			slog.Info("serving https", "addr", k.c.TLSAddr)
-			if err := http.ListenAndServeTLS(k.c.TLSAddr, certFile, keyFile, r); err != nil {
-				slog.Error("https server error", "error", err)
+			if err := k.certs.Watch(runCtx); err != nil {
+				slog.Warn("tls cert reload disabled", "dir", k.c.TLSDir, "error", err)
 			}
 		}()
-	} else {
-		slog.Warn("tls certs not found; skipping https")
 	}
-
-	slog.Info("serving http", "addr", k.c.ServeAddr)
-	if err := http.ListenAndServe(k.c.ServeAddr, r); err != nil {
-		slog.Error("http server error", "error", err)
+	if _, err := os.Stat(k.c.PodInfoDir); err == nil {
+		go func() {
+			if err := k.pod.Watch(runCtx); err != nil {
+				slog.Warn("podinfo reload disabled", "dir", k.c.PodInfoDir, "error", err)
+			}
+		}()
 	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			k.drain(m)
+			enterPhase(phaseShutdown, "grace", k.c.ShutdownGrace.String())
+			cancel()
+		case <-runCtx.Done():
+		}
+	}()
+
+	err := m.Run(runCtx)
+	enterPhase(phaseStopped)
+	return err
 }
 
 func NewApp() *App {
-	k := &App{r: NewSimpleRouter()}
+	k := &App{
+		r:         NewSimpleRouter(),
+		v:         viper.New(),
+		fs:        pflag.NewFlagSet("kuard", pflag.ContinueOnError),
+		overrides: map[string]bool{},
+	}
 
 	// Init all of the subcomponents
-
-	router := k.r
 	k.m = memory.New()
+	k.cpu = cpu.New(cgroup.New(cgroup.DefaultRoot))
 	k.live = debugprobe.New()
 	k.ready = debugprobe.New()
 	k.env = env.New()
 	k.dns = dnsapi.New()
 	k.kg = keygen.New()
 	k.mq = memqserver.NewServer()
-	fsa := fsapi.New()
-
-	// Add handlers
-	for _, prefix := range []string{"", "/a", "/b", "/c"} {
-		if prefix != "" { // variant redirects only for non-root
-			pr := prefix
-			router.GET(prefix+"/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				http.Redirect(w, r, "/?variant="+strings.TrimPrefix(pr, "/"), http.StatusTemporaryRedirect)
-			}))
-			router.GET(prefix+"/-/*path", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-				http.Redirect(w, r, "/?variant="+strings.TrimPrefix(pr, "/"), http.StatusTemporaryRedirect)
-			}))
-		}
-
-		// JSON page info (modern UI uses this)
-		router.GET(prefix+"/pageinfo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			ctx := k.getPageContext(r, prefix)
-			w.Header().Set("Content-Type", "application/json")
-			if err := json.NewEncoder(w).Encode(ctx); err != nil {
-				slog.Error("encode pageinfo", "error", err)
-				w.WriteHeader(http.StatusInternalServerError)
-			}
-		}))
-
-		router.GET(prefix+"/metrics", promhttp.Handler())
-
-		// Add the static files
-		sitedata.AddRoutes(router, prefix+"/built")
-		sitedata.AddRoutes(router, prefix+"/static")
+	k.fsa = fsapi.New()
+	k.faults = faults.New()
+	k.chaos = chaos.New()
+	k.faults.VariantOf = func(r *http.Request) string {
+		s, _ := selectionFrom(r)
+		return s.variant
+	}
+	k.pod = podinfo.New(defaultPodInfoDir)
+	k.pod.Load()
 
-		// Legacy raw file serving remains for direct download.
-		router.GET(prefix+"/fs/*filepath", http.StripPrefix(prefix+"/fs", http.FileServer(http.Dir("/"))))
-		// JSON metadata API for enhanced UI.
-		fsa.AddRoutes(router, prefix+"/fsapi")
+	// Routes for the default config; LoadConfig rebuilds them.
+	k.c.Variants = defaultVariants()
+	a, _ := auth.New(k.c.Auth)
+	k.r, k.variants, _ = k.buildRouter(k.c, a)
+	return k
+}
 
-		k.m.AddRoutes(router, prefix+"/mem")
-		k.live.AddRoutes(router, prefix+"/healthy")
-		k.ready.AddRoutes(router, prefix+"/ready")
-		k.env.AddRoutes(router, prefix+"/env")
-		k.dns.AddRoutes(router, prefix+"/dns")
-		k.kg.AddRoutes(router, prefix+"/keygen")
-		k.mq.AddRoutes(router, prefix+"/memq/server")
+// buildRouter registers the API at the root and under each variant's prefix,
+// protected by a.
+// Route conflicts are reported through the router's Err; the error is for
+// variant names that clash with a root path.
+func (k *App) buildRouter(c Config, a *auth.Auth) (*SimpleRouter, []*variant, error) {
+	sr := NewSimpleRouter()
+	router := k.chaos.Router(a.Router(sr))
+	caps := c.capabilities()
+
+	// Fault rules are checked after variant selection, so they can match on
+	// the selected variant. The /faults API itself is never faulted.
+	withRules := func(r route.Router) route.Router {
+		if !slices.Contains(caps, "faults") {
+			return r
+		}
+		return k.faults.Router(r)
+	}
+	faulted := withRules(router)
+
+	root := &variant{
+		color:   htmlutils.ColorFromString(version.VERSION),
+		version: version.VERSION,
+		live:    k.live,
+		ready:   k.ready,
+		kg:      k.kg,
+	}
+	set := &variantSet{}
+	// The root probes always report on the pod itself, never on a selected
+	// variant, so they bypass selection.
+	k.addVariantRoutes(withRules(selectRouter(router, set)), faulted, root, caps)
+
+	// Introspection
+	introspect := route.WithSubsystem(faulted, "introspection")
+	introspect.GET("/routes", http.HandlerFunc(k.serveRoutes))
+	introspect.GET("/openapi.json", http.HandlerFunc(k.serveOpenAPI))
+
+	k.addConfigRoutes(route.WithSubsystem(faulted, "config"), "/config")
+	if slices.Contains(caps, "faults") {
+		k.faults.AddRoutes(route.WithSubsystem(router, "faults"), "/faults")
 	}
 
 	// Mount Next.js UI at root
+	ui := route.WithSubsystem(faulted, "ui")
 	nextDev := os.Getenv("NEXT_DEV")
 	if nextDev != "" {
 		proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: "localhost:8081"})
 		proxyHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { proxy.ServeHTTP(w, r) })
 		// Explicit common asset paths
-		k.r.GET("/_next/*filepath", proxyHandler)
-		k.r.GET("/favicon.ico", proxyHandler)
-		k.r.GET("/robots.txt", proxyHandler)
-		k.r.GET("/manifest.json", proxyHandler)
-		// Catch-all UI (keep last so API routes above win)
-		k.r.GET("/", proxyHandler)
-		k.r.GET("/*filepath", proxyHandler)
+		ui.GET("/_next/*filepath", proxyHandler)
+		ui.GET("/favicon.ico", proxyHandler)
+		ui.GET("/robots.txt", proxyHandler)
+		ui.GET("/manifest.json", proxyHandler)
+		// Catch-all UI (API routes win by specificity)
+		ui.GET("/", proxyHandler)
+		ui.GET("/*filepath", proxyHandler)
 	} else {
 		// Production: serve pre-exported static site if available
 		if _, err := os.Stat("web/out"); err == nil {
 			fs := http.FileServer(http.Dir("web/out"))
-			k.r.GET("/", fs)
-			k.r.GET("/*filepath", fs)
+			ui.GET("/", fs)
+			ui.GET("/*filepath", fs)
 		} else if _, err := os.Stat("web/.next"); err == nil {
 			// Fallback: serve built assets (not full SSR)
-			k.r.GET("/_next/*filepath", http.FileServer(http.Dir("web")))
+			ui.GET("/_next/*filepath", http.FileServer(http.Dir("web")))
 			// Index fallback
-			k.r.GET("/", http.FileServer(http.Dir("web")))
-			k.r.GET("/*filepath", http.FileServer(http.Dir("web")))
+			ui.GET("/", http.FileServer(http.Dir("web")))
+			ui.GET("/*filepath", http.FileServer(http.Dir("web")))
 		}
 	}
 
-	return k
+	// Variants go last, so their names are checked against every top-level
+	// route.
+	reserved := map[string]bool{}
+	for _, rt := range sr.Routes() {
+		seg, _, _ := strings.Cut(strings.TrimPrefix(rt.Pattern, "/"), "/")
+		reserved[seg] = true
+	}
+	var variants []*variant
+	for _, vc := range c.Variants {
+		if reserved[vc.Name] {
+			for _, v := range variants {
+				v.stop()
+			}
+			return nil, nil, fmt.Errorf("variant %q clashes with the /%s route", vc.Name, vc.Name)
+		}
+		v := newVariant(vc)
+		variants = append(variants, v)
+		vr := variantRouter(router, v)
+		if slices.Contains(caps, "faults") || vc.Delay > 0 || vc.ErrorRate > 0 {
+			// The variant's delay and error rate are fault rules, so they
+			// apply even with the faults subsystem disabled.
+			vr = k.faults.Router(vr)
+		}
+		k.addVariantRoutes(vr, vr, v, caps)
+	}
+	set.add(variants...)
+
+	return sr, variants, nil
+}
+
+// addVariantRoutes registers the API for v under v.prefix, with the liveness
+// and readiness probes on probes. Optional subsystems are only registered if
+// listed in caps.
+func (k *App) addVariantRoutes(router, probes route.Router, v *variant, caps []string) {
+	prefix := v.prefix
+	enabled := func(name string) bool { return slices.Contains(caps, name) }
+	if v.name != "" { // variant redirects only for non-root
+		redirect := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/?variant="+v.name, http.StatusTemporaryRedirect)
+		})
+		variantRoutes := route.WithSubsystem(router, "variant")
+		variantRoutes.GET(prefix+"/", redirect)
+		variantRoutes.GET(prefix+"/-/*path", redirect)
+	}
+
+	// JSON page info (modern UI uses this)
+	route.WithSubsystem(router, "app").GET(prefix+"/pageinfo", route.Describe(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := k.getPageContext(r, v)
+		ctx.Capabilities = caps
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ctx); err != nil {
+			slog.ErrorContext(r.Context(), "encode pageinfo", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}), route.Meta{Summary: "Server and request details", Response: pageContext{}}))
+
+	if enabled("metrics") {
+		route.WithSubsystem(router, "metrics").GET(prefix+"/metrics", promhttp.Handler())
+	}
+
+	// Add the static files
+	static := route.WithSubsystem(router, "sitedata")
+	sitedata.AddRoutes(static, prefix+"/built")
+	sitedata.AddRoutes(static, prefix+"/static")
+
+	// Legacy raw file serving remains for direct download.
+	if enabled("fs") {
+		route.WithSubsystem(router, "fs").GET(prefix+"/fs/*filepath", http.StripPrefix(prefix+"/fs", http.FileServer(http.Dir("/"))))
+	}
+	// JSON metadata API for enhanced UI.
+	if enabled("fsapi") {
+		k.fsa.AddRoutes(route.WithSubsystem(router, "fsapi"), prefix+"/fsapi")
+	}
+
+	if enabled("mem") {
+		k.m.AddRoutes(route.WithSubsystem(router, "mem"), prefix+"/mem")
+	}
+	if enabled("cpu") {
+		k.cpu.AddRoutes(route.WithSubsystem(router, "cpu"), prefix+"/cpu")
+	}
+	if enabled("chaos") {
+		k.chaos.AddRoutes(route.WithSubsystem(router, "chaos"), prefix+"/chaos")
+	}
+	v.live.AddRoutes(route.WithSubsystem(probes, "liveness"), prefix+"/healthy")
+	v.ready.AddRoutes(route.WithSubsystem(probes, "readiness"), prefix+"/ready")
+	if enabled("env") {
+		k.env.AddRoutes(route.WithSubsystem(router, "env"), prefix+"/env")
+	}
+	if enabled("dns") {
+		k.dns.AddRoutes(route.WithSubsystem(router, "dns"), prefix+"/dns")
+	}
+	if enabled("keygen") {
+		v.kg.AddRoutes(route.WithSubsystem(router, "keygen"), prefix+"/keygen")
+	}
+	if enabled("memq") {
+		k.mq.AddRoutes(route.WithSubsystem(router, "memq"), prefix+"/memq/server")
+	}
 }
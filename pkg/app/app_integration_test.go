@@ -2,19 +2,27 @@ package app
 
 import (
 	"encoding/json"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
 func TestAppBasicEndpoints(t *testing.T) {
 	a := NewApp()
+	if err := a.CheckRoutes(); err != nil {
+		t.Fatalf("route conflicts: %v", err)
+	}
 	// Minimal config defaults
 	a.c.ServeAddr = "127.0.0.1:0"
-	srv := httptest.NewServer(promMiddleware(loggingMiddleware(a.r)))
+	srv := httptest.NewServer(a.handler())
 	defer srv.Close()
 
-	endpoints := []string{"/mem/api", "/env/api", "/ready", "/healthy", "/pageinfo"}
+	endpoints := []string{"/mem/api", "/env/api", "/ready", "/healthy", "/pageinfo", "/routes", "/openapi.json"}
 	for _, ep := range endpoints {
 		resp, err := http.Get(srv.URL + ep)
 		if err != nil {
@@ -31,4 +39,120 @@ func TestAppBasicEndpoints(t *testing.T) {
 	if pi["hostname"] == "" {
 		t.Fatalf("expected hostname in pageinfo")
 	}
+	if id := resp.Header.Get("X-Request-ID"); id == "" || pi["requestId"] != id {
+		t.Fatalf("expected request id echoed in header and pageinfo, got %q / %v", id, pi["requestId"])
+	}
+}
+
+func TestAppRoutesAndOpenAPI(t *testing.T) {
+	a := NewApp()
+	srv := httptest.NewServer(a.r)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/routes")
+	if err != nil {
+		t.Fatalf("GET /routes: %v", err)
+	}
+	var routes []routeStatus
+	if err := json.NewDecoder(resp.Body).Decode(&routes); err != nil {
+		t.Fatalf("decode routes: %v", err)
+	}
+	found := false
+	for _, rt := range routes {
+		if rt.Subsystem == "" {
+			t.Fatalf("route %s %s has no subsystem", rt.Method, rt.Pattern)
+		}
+		if rt.Method == http.MethodPost && rt.Pattern == "/memq/server/queues/:name/dequeue" && rt.Subsystem == "memq" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("memq dequeue route missing from /routes")
+	}
+
+	resp, err = http.Get(srv.URL + "/openapi.json")
+	if err != nil {
+		t.Fatalf("GET /openapi.json: %v", err)
+	}
+	var doc struct {
+		Info struct {
+			Description string `json:"description"`
+		} `json:"info"`
+		Paths map[string]map[string]any `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("decode openapi: %v", err)
+	}
+	if _, ok := doc.Paths["/a/pageinfo"]; ok {
+		t.Fatalf("openapi lists variant copies of the root API")
+	}
+	if !strings.Contains(doc.Info.Description, "/a, /b, /c") {
+		t.Fatalf("openapi description does not mention the variant prefixes: %q", doc.Info.Description)
+	}
+	for _, p := range []string{"/pageinfo", "/mem/api", "/env/api", "/dns/api", "/keygen", "/ready/api", "/memq/server/stats", "/memq/server/queues/{name}/dequeue", "/fsapi"} {
+		if _, ok := doc.Paths[p]; !ok {
+			t.Fatalf("openapi missing %s", p)
+		}
+	}
+}
+
+func TestPromMiddlewareRouteLabels(t *testing.T) {
+	a := NewApp()
+	srv := httptest.NewServer(promMiddleware(a.r, nil))
+	defer srv.Close()
+
+	for _, p := range []string{"/fs/etc/hostname", "/no/such/path/1", "/no/such/path/2"} {
+		resp, err := http.Get(srv.URL + p)
+		if err != nil {
+			t.Fatalf("GET %s: %v", p, err)
+		}
+		resp.Body.Close()
+	}
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	metrics := string(body)
+	for _, want := range []string{`route="/fs/*filepath"`, `route="unmatched"`, "requests_in_flight", "response_size_bytes"} {
+		if !strings.Contains(metrics, want) {
+			t.Fatalf("metrics missing %s", want)
+		}
+	}
+	if strings.Contains(metrics, `route="/fs/etc`) || strings.Contains(metrics, `route="/no/such`) {
+		t.Fatalf("raw paths leaked into route label")
+	}
+}
+
+func TestPageInfoPod(t *testing.T) {
+	t.Setenv("POD_NAME", "kuard-xyz")
+	t.Setenv("NODE_NAME", "node-1")
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "labels"), []byte("app=\"kuard\"\n"), 0o644); err != nil {
+		t.Fatalf("write labels: %v", err)
+	}
+	f := filepath.Join(t.TempDir(), "kuard.yaml")
+	writeFile(t, f, "podinfo-dir: "+dir+"\n")
+	a, _, err := loadTestConfig(t, f)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	srv := httptest.NewServer(a.handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/pageinfo")
+	if err != nil {
+		t.Fatalf("GET /pageinfo: %v", err)
+	}
+	var pc pageContext
+	json.NewDecoder(resp.Body).Decode(&pc)
+	if pc.Pod == nil || pc.Pod.Name != "kuard-xyz" || pc.Pod.NodeName != "node-1" || pc.Pod.Labels["app"] != "kuard" {
+		t.Fatalf("unexpected pod info %+v", pc.Pod)
+	}
+	for _, addr := range pc.Addrs {
+		if ip := net.ParseIP(addr); ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+			t.Fatalf("unexpected address %q", addr)
+		}
+	}
 }
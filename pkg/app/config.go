@@ -16,32 +16,133 @@ limitations under the License.
 package app
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/auth"
+	"github.com/kubernetes-up-and-running/kuard/pkg/chaos"
 	"github.com/kubernetes-up-and-running/kuard/pkg/debugprobe"
+	"github.com/kubernetes-up-and-running/kuard/pkg/dirwatch"
+	"github.com/kubernetes-up-and-running/kuard/pkg/env"
+	"github.com/kubernetes-up-and-running/kuard/pkg/faults"
 	"github.com/kubernetes-up-and-running/kuard/pkg/keygen"
+	"github.com/kubernetes-up-and-running/kuard/pkg/memory"
+	"github.com/kubernetes-up-and-running/kuard/pkg/podinfo"
 	"github.com/kubernetes-up-and-running/kuard/pkg/sitedata"
+	"github.com/kubernetes-up-and-running/kuard/pkg/tracing"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
+var configReloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "kuard",
+	Subsystem: "config",
+	Name:      "reloads_total",
+	Help:      "Config file reload attempts by result.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(configReloads)
+}
+
 type Config struct {
+	ConfigFile string `mapstructure:"config"`
+
 	Debug        bool
 	DebugRootDir string `mapstructure:"debug-sitedata-dir"`
 	ServeAddr    string `mapstructure:"address"`
 	TLSAddr      string `mapstructure:"tls-address"`
 	TLSDir       string `mapstructure:"tls-dir"`
 
-	KeyGen keygen.Config
+	TLSSelfSigned bool   `mapstructure:"tls-self-signed"`
+	TLSClientCA   string `mapstructure:"tls-client-ca"`
+	TLSClientAuth string `mapstructure:"tls-client-auth"`
+	AccessLog     string `mapstructure:"access-log"`
+	PodInfoDir    string `mapstructure:"podinfo-dir"`
+
+	// Disable lists optional subsystems whose routes are not registered.
+	Disable []string `mapstructure:"disable"`
+
+	ShutdownGrace time.Duration `mapstructure:"shutdown-grace"`
+
+	KeyGen      keygen.Config
+	Tracing     tracing.Config
+	Auth        auth.Config
+	Env         env.Config
+	Chaos       chaos.Config
+	Mem         memory.Config
+	Termination TerminationConfig
 
 	Liveness  debugprobe.ProbeConfig
 	Readiness debugprobe.ProbeConfig
+
+	Variants []VariantConfig
+	Faults   []faults.Rule
+}
+
+const defaultPodInfoDir = "/etc/podinfo"
+
+// optionalSubsystems can be turned off with --disable.
+var optionalSubsystems = []string{"chaos", "cpu", "dns", "env", "faults", "fs", "fsapi", "keygen", "mem", "memq", "metrics"}
+
+// capabilities returns the optional subsystems that are enabled.
+func (c *Config) capabilities() []string {
+	caps := []string{}
+	for _, name := range optionalSubsystems {
+		if !slices.Contains(c.Disable, name) {
+			caps = append(caps, name)
+		}
+	}
+	return caps
+}
+
+// envPrefix and envKeyReplacer map a key such as keygen.num-to-gen to its
+// environment variable, KUARD_KEYGEN_NUM_TO_GEN.
+const envPrefix = "KUARD"
+
+var envKeyReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+func envVar(key string) string {
+	return envPrefix + "_" + strings.ToUpper(envKeyReplacer.Replace(key))
 }
 
 func (k *App) BindConfig(v *viper.Viper, fs *pflag.FlagSet) {
+	k.v, k.fs = v, fs
+
+	// Every bound key can also be set from the environment. Flags win over
+	// the environment, which wins over the config file.
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(envKeyReplacer)
+	v.AutomaticEnv()
+
 	k.kg.BindConfig(v, fs)
+	tracing.BindConfig(v, fs)
+	auth.BindConfig(v, fs)
+	env.BindConfig(v, fs)
+	chaos.BindConfig(v, fs)
+	memory.BindConfig(v, fs)
 
 	k.live.BindConfig("liveness", v, fs)
 	k.ready.BindConfig("readiness", v, fs)
 
+	// Variants and fault rules are lists of objects and can only be set in
+	// the config file.
+	v.SetDefault("variants", defaultVariants())
+	v.SetDefault("faults", []faults.Rule{})
+
+	fs.String("config", "", "YAML or JSON config file, e.g. from a ConfigMap. Watched and applied live where possible.")
+	v.BindPFlag("config", fs.Lookup("config"))
 	fs.Bool("debug", false, "Debug/devel mode")
 	v.BindPFlag("debug", fs.Lookup("debug"))
 	fs.String("debug-sitedata-dir", "./sitedata", "When in debug/dev mode, directory to find the static assets.")
@@ -52,18 +153,233 @@ func (k *App) BindConfig(v *viper.Viper, fs *pflag.FlagSet) {
 	v.BindPFlag("tls-address", fs.Lookup("tls-address"))
 	fs.String("tls-dir", "/tls", "Directory to look to find TLS certs")
 	v.BindPFlag("tls-dir", fs.Lookup("tls-dir"))
+	fs.Bool("tls-self-signed", false, "Serve https with a generated self-signed cert when none is found in tls-dir")
+	v.BindPFlag("tls-self-signed", fs.Lookup("tls-self-signed"))
+	fs.String("tls-client-ca", "", "PEM file of CAs used to verify client certs on the https listener. Enables mTLS.")
+	v.BindPFlag("tls-client-ca", fs.Lookup("tls-client-ca"))
+	fs.String("tls-client-auth", clientAuthOptional, "Client cert verification when tls-client-ca is set: optional or required")
+	v.BindPFlag("tls-client-auth", fs.Lookup("tls-client-auth"))
+	fs.Duration("shutdown-grace", 5*time.Second, "Deadline for in-flight requests to finish once listeners start shutting down")
+	v.BindPFlag("shutdown-grace", fs.Lookup("shutdown-grace"))
+	fs.Duration("termination-drain-delay", 0, "After SIGTERM, fail readiness and keep serving this long before shutting down")
+	v.BindPFlag("termination.drain-delay", fs.Lookup("termination-drain-delay"))
+	fs.Bool("termination-ignore-sigterm", false, "Ignore SIGTERM to demo SIGKILL after terminationGracePeriodSeconds")
+	v.BindPFlag("termination.ignore-sigterm", fs.Lookup("termination-ignore-sigterm"))
+	fs.StringSlice("disable", nil, "Subsystems to turn off: "+strings.Join(optionalSubsystems, ", "))
+	v.BindPFlag("disable", fs.Lookup("disable"))
+	fs.String("podinfo-dir", defaultPodInfoDir, "Downward API volume with the pod's labels and annotations files")
+	v.BindPFlag("podinfo-dir", fs.Lookup("podinfo-dir"))
+	fs.String("access-log", "json", "Access log format written to stdout: json, logfmt, clf or none")
+	v.BindPFlag("access-log", fs.Lookup("access-log"))
+}
+
+// Validate reports settings that would otherwise fail later, or silently do
+// the wrong thing.
+func (c *Config) Validate() error {
+	var errs []error
+	switch c.AccessLog {
+	case accessLogJSON, accessLogLogfmt, accessLogCLF, accessLogNone, "":
+	default:
+		errs = append(errs, fmt.Errorf("access-log: unknown format %q", c.AccessLog))
+	}
+	switch c.TLSClientAuth {
+	case clientAuthOptional, clientAuthRequired, "":
+	default:
+		errs = append(errs, fmt.Errorf("tls-client-auth: unknown mode %q", c.TLSClientAuth))
+	}
+	if c.ShutdownGrace < 0 {
+		errs = append(errs, errors.New("shutdown-grace: must not be negative"))
+	}
+	if c.Termination.DrainDelay < 0 {
+		errs = append(errs, errors.New("termination.drain-delay: must not be negative"))
+	}
+	if c.KeyGen.NumToGen < 0 || c.KeyGen.TimeToRun < 0 {
+		errs = append(errs, errors.New("keygen: num-to-gen and time-to-run must not be negative"))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("tracing.sample-ratio: must be between 0 and 1"))
+	}
+	if c.Mem.AutoGoMemLimit && (c.Mem.GoMemLimitRatio <= 0 || c.Mem.GoMemLimitRatio > 1) {
+		errs = append(errs, errors.New("mem.gomemlimit-ratio: must be above 0 and at most 1"))
+	}
+	for _, p := range c.Env.MaskPatterns {
+		if _, err := path.Match(p, ""); err != nil {
+			errs = append(errs, fmt.Errorf("env.mask-patterns: %q: %w", p, err))
+		}
+	}
+	for _, name := range c.Disable {
+		if !slices.Contains(optionalSubsystems, name) {
+			errs = append(errs, fmt.Errorf("disable: unknown subsystem %q", name))
+		}
+	}
+	if err := validateVariants(c.Variants); err != nil {
+		errs = append(errs, err)
+	}
+	if err := faults.ValidateRules(c.Faults); err != nil {
+		errs = append(errs, fmt.Errorf("faults: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func decodeConfig(v *viper.Viper) (Config, error) {
+	var c Config
+	if err := v.UnmarshalExact(&c); err != nil {
+		return c, err
+	}
+	return c, c.Validate()
 }
 
-func (k *App) LoadConfig(v *viper.Viper) {
-	err := v.UnmarshalExact(&k.c)
+// LoadConfig reads the --config file, if any, and applies the merged
+// settings. Nothing is applied if they are invalid.
+func (k *App) LoadConfig(v *viper.Viper) error {
+	if f := v.GetString("config"); f != "" {
+		v.SetConfigFile(f)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("reading config file: %w", err)
+		}
+	}
+	c, err := decodeConfig(v)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	k.live.SetConfig(k.c.Liveness)
-	k.ready.SetConfig(k.c.Readiness)
+	al, err := newAccessLogger(c.AccessLog, os.Stdout)
+	if err != nil {
+		return err
+	}
+	a, err := auth.New(c.Auth)
+	if err != nil {
+		return err
+	}
+	r, variants, err := k.buildRouter(c, a)
+	if err != nil {
+		return err
+	}
+	for _, v := range k.variants {
+		v.stop()
+	}
+	k.accessLog = al
+	k.r, k.variants = r, variants
 
-	k.kg.LoadConfig(k.c.KeyGen)
+	if !a.Enabled() {
+		slog.Warn("no authentication configured; env, fs and fsapi are open to anyone")
+	}
 
-	sitedata.SetConfig(k.c.Debug, k.c.DebugRootDir)
+	k.pod = podinfo.New(c.PodInfoDir)
+	if err := k.pod.Load(); err != nil {
+		slog.Warn("could not read podinfo", "dir", c.PodInfoDir, "error", err)
+	}
+
+	k.mu.Lock()
+	k.c = c
+	k.snapshotConfig()
+	k.mu.Unlock()
+
+	k.env.SetConfig(c.Env)
+	k.chaos.SetConfig(c.Chaos)
+	k.m.SetConfig(c.Mem)
+	k.live.SetConfig(c.Liveness)
+	k.ready.SetConfig(c.Readiness)
+
+	k.kg.LoadConfig(c.KeyGen)
+	if err := k.faults.SetRules(c.Faults); err != nil {
+		return err
+	}
+	if err := k.faults.SetFixedRules(variantFaultRules(c.Variants)); err != nil {
+		return err
+	}
+
+	sitedata.SetConfig(c.Debug, c.DebugRootDir)
+	return nil
+}
+
+// WatchConfig applies edits to the --config file as they happen, including
+// the symlink swap used for ConfigMap volumes. Probe and KeyGen settings take
+// effect immediately; other changes are logged as needing a restart.
+//
+// viper's own WatchConfig is not used: it re-reads the file on a goroutine of
+// its own, racing with PATCH /config, which also writes to v. Here every
+// access to v happens under k.mu.
+func (k *App) WatchConfig(v *viper.Viper) {
+	file := v.ConfigFileUsed()
+	if file == "" {
+		return
+	}
+	go func() {
+		err := dirwatch.Watch(context.Background(), filepath.Dir(file), func() { k.reloadConfig(v) })
+		if err != nil {
+			slog.Warn("config reload disabled", "file", file, "error", err)
+		}
+	}()
+}
+
+func (k *App) reloadConfig(v *viper.Viper) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// Read under k.mu so the file never races PATCH /config writing to v.
+	if err := v.ReadInConfig(); err != nil {
+		configReloads.WithLabelValues("error").Inc()
+		slog.Error("config reload rejected", "file", v.ConfigFileUsed(), "error", err)
+		return
+	}
+	c, err := decodeConfig(v)
+	if err != nil {
+		configReloads.WithLabelValues("error").Inc()
+		slog.Error("config reload rejected", "file", v.ConfigFileUsed(), "error", err)
+		return
+	}
+	configReloads.WithLabelValues("success").Inc()
+
+	applied := k.applyLive(c)
+	k.snapshotConfig()
+	slog.Info("config reloaded", "file", v.ConfigFileUsed(), "applied", applied)
+	if !reflect.DeepEqual(restartOnly(c), restartOnly(k.c)) {
+		slog.Warn("some config changes need a restart to take effect", "file", v.ConfigFileUsed())
+	}
+}
+
+// restartOnly returns c without the sections applyLive handles, leaving the
+// settings that only take effect on a restart.
+func restartOnly(c Config) Config {
+	c.Liveness, c.Readiness = debugprobe.ProbeConfig{}, debugprobe.ProbeConfig{}
+	c.KeyGen = keygen.Config{}
+	c.Faults = nil
+	return c
+}
+
+// liveSections are the config sections that can change without a restart.
+var liveSections = []string{"liveness", "readiness", "keygen"}
+
+// applyLive copies the live sections of c into the running config and pushes
+// the ones that changed, returning their names. Only changed sections are
+// pushed so an edit elsewhere doesn't reset a probe's fail-next countdown or
+// restart the KeyGen workload. Fault rules are replaced too when the file's
+// list changed, dropping any added through /faults; they are not in
+// liveSections as a list can't be patched key by key. k.mu must be held.
+func (k *App) applyLive(c Config) []string {
+	old := k.c
+	k.c.Liveness = c.Liveness
+	k.c.Readiness = c.Readiness
+	k.c.KeyGen = c.KeyGen
+	k.c.Faults = c.Faults
+
+	var applied []string
+	if c.Liveness != old.Liveness {
+		k.live.SetConfig(c.Liveness)
+		applied = append(applied, "liveness")
+	}
+	if c.Readiness != old.Readiness {
+		k.ready.SetConfig(c.Readiness)
+		applied = append(applied, "readiness")
+	}
+	if c.KeyGen != old.KeyGen {
+		k.kg.LoadConfig(c.KeyGen)
+		applied = append(applied, "keygen")
+	}
+	if !reflect.DeepEqual(c.Faults, old.Faults) {
+		k.faults.SetRules(c.Faults)
+		applied = append(applied, "faults")
+	}
+	return applied
 }
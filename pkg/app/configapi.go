@@ -0,0 +1,178 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/apiutils"
+	"github.com/kubernetes-up-and-running/kuard/pkg/redact"
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+// Where a setting's value came from, highest precedence first.
+const (
+	sourceAPI     = "api"
+	sourceFlag    = "flag"
+	sourceEnv     = "env"
+	sourceFile    = "file"
+	sourceDefault = "default"
+)
+
+// configSetting is one effective setting and where its value came from.
+type configSetting struct {
+	Key     string `json:"key"`
+	Value   any    `json:"value"`
+	Source  string `json:"source"`
+	Mutable bool   `json:"mutable"`
+}
+
+type configStatus struct {
+	File     string          `json:"file,omitempty"`
+	Settings []configSetting `json:"settings"`
+}
+
+// configPatch maps dotted keys, e.g. "readiness.fail-next", to new values.
+type configPatch map[string]any
+
+func (k *App) addConfigRoutes(r route.Router, base string) {
+	r.GET(base, route.Describe(http.HandlerFunc(k.getConfig), route.Meta{
+		Summary:  "Effective configuration with the source of each value",
+		Response: configStatus{},
+	}))
+	r.PATCH(base, route.Describe(http.HandlerFunc(k.patchConfig), route.Meta{
+		Summary:  "Change liveness, readiness or keygen settings at runtime",
+		Request:  configPatch{},
+		Response: configStatus{},
+	}))
+}
+
+func (k *App) getConfig(w http.ResponseWriter, r *http.Request) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	apiutils.ServeJSON(w, k.configStatus())
+}
+
+func (k *App) patchConfig(w http.ResponseWriter, r *http.Request) {
+	patch := configPatch{}
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	keys := make([]string, 0, len(patch))
+	for key := range patch {
+		if !mutableKey(key) {
+			http.Error(w, fmt.Sprintf("%s cannot be changed at runtime", key), http.StatusBadRequest)
+			return
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// Check the patch against a copy of the running config so nothing is
+	// touched if it is invalid.
+	c := k.c
+	pv := viper.New()
+	for key, val := range patch {
+		pv.Set(key, val)
+	}
+	if err := pv.UnmarshalExact(&c); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := c.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for key, val := range patch {
+		k.v.Set(key, val)
+		k.overrides[key] = true
+	}
+	applied := k.applyLive(c)
+	k.snapshotConfig()
+	slog.InfoContext(r.Context(), "config patched", "keys", keys, "applied", applied)
+
+	apiutils.ServeJSON(w, k.configStatus())
+}
+
+func mutableKey(key string) bool {
+	for _, s := range liveSections {
+		if strings.HasPrefix(key, s+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshotConfig records the settings just applied, so /config keeps showing
+// them if a later file reload is rejected. k.mu must be held.
+func (k *App) snapshotConfig() {
+	keys := k.v.AllKeys()
+	sort.Strings(keys)
+	k.settings = k.settings[:0]
+	fromEnv := map[string]string{}
+	for _, key := range keys {
+		s := configSetting{
+			Key:     key,
+			Value:   redact.Value(key, k.v.Get(key)),
+			Source:  k.configSource(key),
+			Mutable: mutableKey(key),
+		}
+		if s.Source == sourceEnv {
+			fromEnv[envVar(key)] = key
+		}
+		k.settings = append(k.settings, s)
+	}
+	k.env.SetConfigVars(fromEnv)
+	k.configFile = k.v.ConfigFileUsed()
+}
+
+func (k *App) configSource(key string) string {
+	if k.overrides[key] {
+		return sourceAPI
+	}
+	// Flags are named after their key with dots replaced by dashes.
+	if f := k.fs.Lookup(strings.ReplaceAll(key, ".", "-")); f != nil && f.Changed {
+		return sourceFlag
+	}
+	if _, ok := os.LookupEnv(envVar(key)); ok {
+		return sourceEnv
+	}
+	if k.v.InConfig(key) {
+		return sourceFile
+	}
+	return sourceDefault
+}
+
+// configStatus returns a copy of the last snapshot. k.mu must be held.
+func (k *App) configStatus() configStatus {
+	return configStatus{
+		File:     k.configFile,
+		Settings: append([]configSetting{}, k.settings...),
+	}
+}
@@ -1,7 +1,10 @@
 package app
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 
 	"github.com/kubernetes-up-and-running/kuard/pkg/route"
@@ -12,22 +15,114 @@ type srvRoute struct {
 	method  string
 	pattern string // e.g. /foo/bar/*filepath or /foo/:id
 	handler http.Handler
+	meta    route.Meta
+
+	// Precomputed for specificity ordering and conflict detection.
+	exact    bool   // no :name or *name segments
+	static   int    // length of the static prefix before the first capture
+	wildcard bool   // ends in *name
+	shape    string // pattern with capture names removed
+}
+
+func newSrvRoute(method, pattern string, h http.Handler) srvRoute {
+	rt := srvRoute{method: method, pattern: pattern, handler: h}
+	rt.meta, _ = route.MetaOf(h)
+	rt.static = strings.IndexAny(pattern, ":*")
+	rt.exact = rt.static < 0
+	if rt.exact {
+		rt.static = len(pattern)
+	}
+	rt.wildcard = strings.Contains(pattern, "*")
+
+	var b strings.Builder
+	for _, seg := range strings.Split(pattern, "/") {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			seg = seg[:1]
+		}
+		b.WriteString(seg)
+		b.WriteByte('/')
+	}
+	rt.shape = b.String()
+	return rt
+}
+
+// catchAll reports whether rt is a wildcard at the root, matching any path.
+func (rt srvRoute) catchAll() bool {
+	return rt.wildcard && rt.static == 1
+}
+
+// moreSpecific reports whether a should be tried before b: exact patterns
+// first, then the longest static prefix, then :name patterns before *name
+// wildcards.
+func moreSpecific(a, b srvRoute) bool {
+	if a.exact != b.exact {
+		return a.exact
+	}
+	if a.static != b.static {
+		return a.static > b.static
+	}
+	if a.wildcard != b.wildcard {
+		return !a.wildcard
+	}
+	return len(a.shape) > len(b.shape)
 }
 
 // SimpleRouter provides a tiny subset of httprouter features using net/http only.
 // Supported:
-//   - GET/POST/PUT routes
-//   - Wildcard suffix *name (captures remainder in URL Path without leading slash)
-//   - No parameter extraction for :name segments yet (not currently needed by existing handlers)
+//   - GET/POST/PUT/PATCH/DELETE routes
+//   - Named segments :name (matches exactly one non-empty path segment)
+//   - Wildcard suffix *name (captures remainder of the path, possibly empty)
+//   - Captured values are available to handlers via route.Param
+//   - HEAD served by the GET handler, as net/http's ServeMux does
+//   - 405 Method Not Allowed (with Allow header) when only the method differs,
+//     ignoring root catch-alls such as /*filepath
+//   - Most specific match wins regardless of registration order
 //   - Trailing slash normalization left to caller
+//
+// Registering the same method and pattern twice, or two patterns that differ
+// only in capture names, is recorded as a conflict and reported by Err.
 type SimpleRouter struct {
-	routes []srvRoute
+	routes    []srvRoute
+	conflicts []error
 }
 
 func NewSimpleRouter() *SimpleRouter { return &SimpleRouter{routes: []srvRoute{}} }
 
 func (sr *SimpleRouter) handle(method, pattern string, h http.Handler) {
-	sr.routes = append(sr.routes, srvRoute{method: method, pattern: pattern, handler: h})
+	rt := newSrvRoute(method, pattern, h)
+	for _, existing := range sr.routes {
+		if existing.method != method || existing.shape != rt.shape {
+			continue
+		}
+		if existing.pattern == pattern {
+			sr.conflicts = append(sr.conflicts, fmt.Errorf("duplicate route %s %s", method, pattern))
+		} else {
+			sr.conflicts = append(sr.conflicts, fmt.Errorf("route %s %s conflicts with %s", method, pattern, existing.pattern))
+		}
+	}
+
+	sr.routes = append(sr.routes, rt)
+	sort.SliceStable(sr.routes, func(i, j int) bool { return moreSpecific(sr.routes[i], sr.routes[j]) })
+}
+
+// Err returns all duplicate or conflicting registrations seen so far, or nil.
+func (sr *SimpleRouter) Err() error {
+	return errors.Join(sr.conflicts...)
+}
+
+// Routes lists every registration, ordered by pattern then method.
+func (sr *SimpleRouter) Routes() []route.Info {
+	out := make([]route.Info, 0, len(sr.routes))
+	for _, rt := range sr.routes {
+		out = append(out, route.Info{Method: rt.method, Pattern: rt.pattern, Meta: rt.meta})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Pattern != out[j].Pattern {
+			return out[i].Pattern < out[j].Pattern
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
 }
 
 // Convenience methods.
@@ -36,6 +131,9 @@ var _ route.Router = (*SimpleRouter)(nil)
 func (sr *SimpleRouter) GET(pattern string, h http.Handler)  { sr.handle(http.MethodGet, pattern, h) }
 func (sr *SimpleRouter) POST(pattern string, h http.Handler) { sr.handle(http.MethodPost, pattern, h) }
 func (sr *SimpleRouter) PUT(pattern string, h http.Handler)  { sr.handle(http.MethodPut, pattern, h) }
+func (sr *SimpleRouter) PATCH(pattern string, h http.Handler) {
+	sr.handle(http.MethodPatch, pattern, h)
+}
 func (sr *SimpleRouter) DELETE(pattern string, h http.Handler) {
 	sr.handle(http.MethodDelete, pattern, h)
 }
@@ -44,34 +142,94 @@ func (sr *SimpleRouter) DELETE(pattern string, h http.Handler) {
 func (sr *SimpleRouter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Path
 	method := r.Method
+	var allowed []string
 	for _, rt := range sr.routes {
-		if rt.method != method {
+		params, ok := matchPattern(rt.pattern, path)
+		if !ok {
+			continue
+		}
+		if rt.method != method && !(method == http.MethodHead && rt.method == http.MethodGet) {
+			// A root catch-all such as the UI's /*filepath matches every
+			// path, so it would turn every unknown path into a 405.
+			if !rt.catchAll() {
+				allowed = append(allowed, rt.method)
+				if rt.method == http.MethodGet {
+					allowed = append(allowed, http.MethodHead)
+				}
+			}
 			continue
 		}
-		if matchPattern(rt.pattern, path) {
-			// Inject wildcard capture as request context if needed later.
-			rt.handler.ServeHTTP(w, r)
-			return
+		// Record the pattern even without a middleware asking for it, so
+		// handlers behind a rewrite still see what the client requested.
+		ctx, m := route.EnsureMatch(r.Context())
+		m.Pattern = rt.pattern
+		if len(params) > 0 {
+			ctx = route.WithParams(ctx, params)
 		}
+		if ctx != r.Context() {
+			r = r.WithContext(ctx)
+		}
+		rt.handler.ServeHTTP(w, r)
+		return
+	}
+	if len(allowed) > 0 {
+		w.Header().Set("Allow", allowHeader(allowed))
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
 	}
 	http.NotFound(w, r)
 }
 
-// matchPattern matches a very small subset of patterns with optional final wildcard.
-func matchPattern(pattern, path string) bool {
-	if pattern == path {
-		return true
+// allowHeader returns a sorted, de-duplicated Allow header value.
+func allowHeader(methods []string) string {
+	sort.Strings(methods)
+	out := methods[:0]
+	for i, m := range methods {
+		if i == 0 || m != methods[i-1] {
+			out = append(out, m)
+		}
 	}
-	// Wildcard support: pattern ending with /*name
-	if strings.Contains(pattern, "*") {
-		base := pattern
-		if i := strings.Index(pattern, "*"); i >= 0 {
+	return strings.Join(out, ", ")
+}
+
+// matchPattern matches path against pattern. Static text must match exactly,
+// :name consumes one non-empty segment and a final *name consumes the rest of
+// the path. Captured values are returned keyed by name.
+func matchPattern(pattern, path string) (route.Params, bool) {
+	var params route.Params
+	for {
+		i := strings.IndexAny(pattern, ":*")
+		if i < 0 {
+			return params, pattern == path
+		}
+		if !strings.HasPrefix(path, pattern[:i]) {
+			return nil, false
+		}
+		path = path[i:]
+		pattern = pattern[i:]
+		if params == nil {
+			params = route.Params{}
+		}
+
+		if pattern[0] == '*' {
 			// keep slash before * so /foo/*bar matches /foo/ and deeper
-			base = pattern[:i]
+			params[pattern[1:]] = path
+			return params, true
+		}
+
+		name := pattern[1:]
+		pattern = ""
+		if j := strings.IndexByte(name, '/'); j >= 0 {
+			name, pattern = name[:j], name[j:]
+		}
+		end := strings.IndexByte(path, '/')
+		if end < 0 {
+			end = len(path)
 		}
-		if strings.HasPrefix(path, base) {
-			return true
+		if end == 0 {
+			return nil, false
 		}
+		params[name] = path[:end]
+		path = path[end:]
 	}
-	return false
 }
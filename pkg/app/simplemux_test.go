@@ -0,0 +1,143 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+func TestSimpleRouterParams(t *testing.T) {
+	sr := NewSimpleRouter()
+	var got route.Params
+	capture := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = route.ParamsFromContext(r.Context())
+	})
+	sr.POST("/memq/server/queues/:name/dequeue", capture)
+	sr.GET("/fs/*filepath", capture)
+
+	w := httptest.NewRecorder()
+	sr.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/memq/server/queues/work/dequeue", nil))
+	if w.Code != 200 || got["name"] != "work" {
+		t.Fatalf("expected name=work, got %d %v", w.Code, got)
+	}
+
+	w = httptest.NewRecorder()
+	sr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/fs/etc/hosts", nil))
+	if w.Code != 200 || got["filepath"] != "etc/hosts" {
+		t.Fatalf("expected filepath=etc/hosts, got %d %v", w.Code, got)
+	}
+
+	// An empty segment must not satisfy :name.
+	w = httptest.NewRecorder()
+	sr.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/memq/server/queues//dequeue", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for empty segment, got %d", w.Code)
+	}
+}
+
+func TestSimpleRouterMethodNotAllowed(t *testing.T) {
+	sr := NewSimpleRouter()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	sr.GET("/ready/api", ok)
+	sr.PUT("/ready/api", ok)
+
+	w := httptest.NewRecorder()
+	sr.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/ready/api", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405 got %d", w.Code)
+	}
+	if allow := w.Header().Get("Allow"); allow != "GET, HEAD, PUT" {
+		t.Fatalf("unexpected Allow header %q", allow)
+	}
+
+	w = httptest.NewRecorder()
+	sr.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/nope", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 got %d", w.Code)
+	}
+}
+
+func TestSimpleRouterHead(t *testing.T) {
+	sr := NewSimpleRouter()
+	sr.GET("/pageinfo", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }))
+	sr.POST("/keygen", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	sr.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/pageinfo", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("HEAD on a GET route: expected 200 got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	sr.ServeHTTP(w, httptest.NewRequest(http.MethodHead, "/keygen", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST" {
+		t.Fatalf("HEAD on a POST route: expected 405 with Allow POST, got %d %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestSimpleRouterCatchAllIsNot405(t *testing.T) {
+	sr := NewSimpleRouter()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	sr.GET("/*filepath", ok)
+	sr.GET("/fs/*filepath", ok)
+
+	w := httptest.NewRecorder()
+	sr.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/nope", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("POST /nope: expected 404 got %d (Allow %q)", w.Code, w.Header().Get("Allow"))
+	}
+
+	w = httptest.NewRecorder()
+	sr.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/fs/etc", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+		t.Fatalf("POST /fs/etc: expected 405 with Allow GET, HEAD, got %d %q", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestSimpleRouterSpecificity(t *testing.T) {
+	sr := NewSimpleRouter()
+	var hit string
+	named := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { hit = name })
+	}
+	// Registered least specific first on purpose.
+	sr.GET("/*filepath", named("catchall"))
+	sr.GET("/fs/*filepath", named("fs"))
+	sr.GET("/fs/:name", named("param"))
+	sr.GET("/fs/special", named("exact"))
+
+	cases := map[string]string{
+		"/fs/special":    "exact",
+		"/fs/other":      "param",
+		"/fs/other/deep": "fs",
+		"/index.html":    "catchall",
+	}
+	for path, want := range cases {
+		hit = ""
+		sr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+		if hit != want {
+			t.Fatalf("%s: expected %s got %s", path, want, hit)
+		}
+	}
+	if err := sr.Err(); err != nil {
+		t.Fatalf("unexpected conflicts: %v", err)
+	}
+}
+
+func TestSimpleRouterConflicts(t *testing.T) {
+	sr := NewSimpleRouter()
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	sr.GET("/mem/api", h)
+	sr.GET("/mem/api", h)
+	sr.PUT("/queues/:name", h)
+	sr.PUT("/queues/:queue", h)
+	sr.POST("/queues/:name", h) // different method, no conflict
+
+	if err := sr.Err(); err == nil {
+		t.Fatalf("expected conflicts to be reported")
+	} else if got := len(sr.conflicts); got != 2 {
+		t.Fatalf("expected 2 conflicts got %d: %v", got, err)
+	}
+}
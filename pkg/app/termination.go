@@ -0,0 +1,120 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package app
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/lifecycle"
+)
+
+// TerminationConfig controls how kuard behaves when asked to stop.
+type TerminationConfig struct {
+	// DrainDelay is how long to keep serving, with readiness failing, after a
+	// termination signal and before listeners are shut down.
+	DrainDelay time.Duration `json:"drainDelay" mapstructure:"drain-delay"`
+
+	// IgnoreSIGTERM logs and ignores SIGTERM so the kubelet has to SIGKILL
+	// the container after terminationGracePeriodSeconds.
+	IgnoreSIGTERM bool `json:"ignoreSigterm" mapstructure:"ignore-sigterm"`
+}
+
+// Termination phases, in order.
+const (
+	phaseRunning  = "running"
+	phaseIgnoring = "ignoring-sigterm"
+	phaseDraining = "draining"
+	phaseShutdown = "shutdown"
+	phaseStopped  = "stopped"
+)
+
+var terminationPhases = []string{phaseRunning, phaseIgnoring, phaseDraining, phaseShutdown, phaseStopped}
+
+var (
+	terminationPhase = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kuard",
+		Subsystem: "termination",
+		Name:      "phase",
+		Help:      "1 for the current termination phase, else 0.",
+	}, []string{"phase"})
+	terminationPhaseStart = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "kuard",
+		Subsystem: "termination",
+		Name:      "phase_start_timestamp_seconds",
+		Help:      "Unix time each termination phase was entered.",
+	}, []string{"phase"})
+)
+
+func init() {
+	prometheus.MustRegister(terminationPhase, terminationPhaseStart)
+	enterPhase(phaseRunning)
+}
+
+func enterPhase(phase string, args ...any) {
+	for _, p := range terminationPhases {
+		v := 0.0
+		if p == phase {
+			v = 1
+		}
+		terminationPhase.WithLabelValues(p).Set(v)
+	}
+	terminationPhaseStart.WithLabelValues(phase).SetToCurrentTime()
+	if phase != phaseRunning {
+		slog.Info("termination phase", append([]any{"phase", phase}, args...)...)
+	}
+}
+
+// HandleSignals returns a context that is cancelled when the first accepted
+// termination signal arrives. SIGTERM is ignored when configured to. Any
+// signal after the accepted one exits immediately.
+func (k *App) HandleSignals(sigs <-chan os.Signal) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		for sig := range sigs {
+			if sig == syscall.SIGTERM && k.c.Termination.IgnoreSIGTERM {
+				enterPhase(phaseIgnoring, "signal", sig.String())
+				continue
+			}
+			slog.Info("termination signal received", "signal", sig.String())
+			cancel()
+			break
+		}
+		for sig := range sigs {
+			slog.Warn("second termination signal; exiting immediately", "signal", sig.String())
+			os.Exit(1)
+		}
+	}()
+	return ctx
+}
+
+// drain fails readiness, the root's and every variant's, and stops
+// keep-alives, then keeps serving for the configured drain delay so load
+// balancers can stop routing to this pod.
+func (k *App) drain(m *lifecycle.Manager) {
+	enterPhase(phaseDraining, "delay", k.c.Termination.DrainDelay.String())
+	k.ready.SetDraining(true)
+	for _, v := range k.variants {
+		v.ready.SetDraining(true)
+	}
+	m.SetKeepAlivesEnabled(false)
+	time.Sleep(k.c.Termination.DrainDelay)
+}
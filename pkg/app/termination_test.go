@@ -0,0 +1,67 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/lifecycle"
+)
+
+func TestDrainFailsReadiness(t *testing.T) {
+	a := NewApp()
+	a.c.Termination.DrainDelay = 10 * time.Millisecond
+	srv := httptest.NewServer(a.handler())
+	defer srv.Close()
+
+	start := time.Now()
+	a.drain(lifecycle.New(time.Second))
+	if d := time.Since(start); d < a.c.Termination.DrainDelay {
+		t.Fatalf("drain returned after %v, want at least %v", d, a.c.Termination.DrainDelay)
+	}
+
+	for _, path := range []string{"/ready", "/a/ready", "/b/ready", "/c/ready"} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("%s status %d while draining, want 503", path, resp.StatusCode)
+		}
+	}
+	if v := testutil.ToFloat64(terminationPhase.WithLabelValues(phaseDraining)); v != 1 {
+		t.Fatalf("draining phase gauge = %v, want 1", v)
+	}
+	if v := testutil.ToFloat64(terminationPhase.WithLabelValues(phaseRunning)); v != 0 {
+		t.Fatalf("running phase gauge = %v, want 0", v)
+	}
+	enterPhase(phaseRunning)
+}
+
+func TestHandleSignalsIgnoreSIGTERM(t *testing.T) {
+	a := NewApp()
+	a.c.Termination.IgnoreSIGTERM = true
+	sigs := make(chan os.Signal, 1)
+	ctx := a.HandleSignals(sigs)
+
+	sigs <- syscall.SIGTERM
+	select {
+	case <-ctx.Done():
+		t.Fatalf("context cancelled by ignored SIGTERM")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	sigs <- syscall.SIGINT
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("context not cancelled by SIGINT")
+	}
+	enterPhase(phaseRunning)
+}
@@ -0,0 +1,116 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package app
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Client certificate modes accepted by --tls-client-auth.
+const (
+	clientAuthOptional = "optional"
+	clientAuthRequired = "required"
+)
+
+// tlsInfo describes the TLS connection a request arrived on.
+type tlsInfo struct {
+	Version     string          `json:"version"`
+	CipherSuite string          `json:"cipherSuite"`
+	ALPN        string          `json:"alpn"`
+	ServerName  string          `json:"serverName"`
+	ClientCert  *clientCertInfo `json:"clientCert,omitempty"`
+}
+
+// clientCertInfo describes a verified client certificate.
+type clientCertInfo struct {
+	Subject     string    `json:"subject"`
+	Issuer      string    `json:"issuer"`
+	Serial      string    `json:"serial"`
+	DNSNames    []string  `json:"dnsNames,omitempty"`
+	IPAddresses []string  `json:"ipAddresses,omitempty"`
+	URIs        []string  `json:"uris,omitempty"`
+	Emails      []string  `json:"emails,omitempty"`
+	NotBefore   time.Time `json:"notBefore"`
+	NotAfter    time.Time `json:"notAfter"`
+}
+
+func getTLSInfo(cs *tls.ConnectionState) *tlsInfo {
+	if cs == nil {
+		return nil
+	}
+	info := &tlsInfo{
+		Version:     tls.VersionName(cs.Version),
+		CipherSuite: tls.CipherSuiteName(cs.CipherSuite),
+		ALPN:        cs.NegotiatedProtocol,
+		ServerName:  cs.ServerName,
+	}
+	// Only report certificates that passed verification against the client CA.
+	if len(cs.VerifiedChains) > 0 && len(cs.VerifiedChains[0]) > 0 {
+		info.ClientCert = getClientCertInfo(cs.VerifiedChains[0][0])
+	}
+	return info
+}
+
+func getClientCertInfo(c *x509.Certificate) *clientCertInfo {
+	ci := &clientCertInfo{
+		Subject:   c.Subject.String(),
+		Issuer:    c.Issuer.String(),
+		Serial:    c.SerialNumber.Text(16),
+		DNSNames:  c.DNSNames,
+		Emails:    c.EmailAddresses,
+		NotBefore: c.NotBefore,
+		NotAfter:  c.NotAfter,
+	}
+	for _, ip := range c.IPAddresses {
+		ci.IPAddresses = append(ci.IPAddresses, ip.String())
+	}
+	for _, u := range c.URIs {
+		ci.URIs = append(ci.URIs, u.String())
+	}
+	return ci
+}
+
+// clientAuthConfig applies the --tls-client-ca and --tls-client-auth
+// settings to tc.
+func clientAuthConfig(tc *tls.Config, caFile, mode string) error {
+	if caFile == "" {
+		return nil
+	}
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return errors.New("no certificates found in " + caFile)
+	}
+	tc.ClientCAs = pool
+
+	switch mode {
+	case clientAuthOptional, "":
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	case clientAuthRequired:
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return fmt.Errorf("unknown client auth mode %q", mode)
+	}
+	return nil
+}
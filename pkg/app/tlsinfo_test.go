@@ -0,0 +1,108 @@
+package app
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newClientCert returns a CA PEM and a client certificate signed by it.
+func newClientCert(t *testing.T) ([]byte, tls.Certificate) {
+	t.Helper()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ = x509.ParseCertificate(caDER)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	spiffe, _ := url.Parse("spiffe://cluster.local/ns/default/sa/client")
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client"},
+		URIs:         []*url.URL{spiffe},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}),
+		tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestPageInfoClientCert(t *testing.T) {
+	caPEM, clientCert := newClientCert(t)
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	os.WriteFile(caFile, caPEM, 0o644)
+
+	a := NewApp()
+	srv := httptest.NewUnstartedServer(a.handler())
+	srv.TLS = &tls.Config{}
+	if err := clientAuthConfig(srv.TLS, caFile, clientAuthRequired); err != nil {
+		t.Fatalf("client auth config: %v", err)
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	// Without a client cert the handshake must fail.
+	if _, err := srv.Client().Get(srv.URL + "/pageinfo"); err == nil {
+		t.Fatalf("expected handshake failure without client cert")
+	}
+
+	client := srv.Client()
+	client.Transport.(*http.Transport).TLSClientConfig.Certificates = []tls.Certificate{clientCert}
+	resp, err := client.Get(srv.URL + "/pageinfo")
+	if err != nil {
+		t.Fatalf("GET /pageinfo: %v", err)
+	}
+	var pi pageContext
+	if err := json.NewDecoder(resp.Body).Decode(&pi); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if pi.TLS == nil || pi.TLS.Version == "" || pi.TLS.CipherSuite == "" {
+		t.Fatalf("missing tls info: %+v", pi.TLS)
+	}
+	cc := pi.TLS.ClientCert
+	if cc == nil || cc.Subject != "CN=client" || cc.Issuer != "CN=test-ca" || len(cc.URIs) != 1 {
+		t.Fatalf("unexpected client cert info: %+v", cc)
+	}
+}
+
+func TestClientAuthConfigErrors(t *testing.T) {
+	if err := clientAuthConfig(&tls.Config{}, filepath.Join(t.TempDir(), "missing"), clientAuthOptional); err == nil {
+		t.Fatalf("expected error for missing CA file")
+	}
+	caPEM, _ := newClientCert(t)
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	os.WriteFile(caFile, caPEM, 0o644)
+	if err := clientAuthConfig(&tls.Config{}, caFile, "sometimes"); err == nil {
+		t.Fatalf("expected error for unknown mode")
+	}
+}
@@ -0,0 +1,282 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/debugprobe"
+	"github.com/kubernetes-up-and-running/kuard/pkg/faults"
+	"github.com/kubernetes-up-and-running/kuard/pkg/htmlutils"
+	"github.com/kubernetes-up-and-running/kuard/pkg/keygen"
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+	"github.com/kubernetes-up-and-running/kuard/pkg/version"
+)
+
+// VariantConfig defines a variant: a copy of the kuard API served under
+// /<name> that looks and behaves like a different backend.
+type VariantConfig struct {
+	Name string `json:"name"`
+	// Color and Version are reported in pageinfo. They default to a color
+	// derived from the name and to the real version.
+	Color   string `json:"color"`
+	Version string `json:"version"`
+
+	Liveness  debugprobe.ProbeConfig `json:"liveness"`
+	Readiness debugprobe.ProbeConfig `json:"readiness"`
+	KeyGen    keygen.Config          `json:"keygen"`
+
+	// Delay is added to every response. ErrorRate is the fraction of
+	// requests, 0 to 1, that fail with a 500.
+	Delay     time.Duration `json:"delay"`
+	ErrorRate float64       `json:"errorRate" mapstructure:"error-rate"`
+
+	// Weight is this variant's share of root requests that don't pick a
+	// variant by header or cookie. Assignments are made sticky with a cookie.
+	Weight int `json:"weight"`
+}
+
+// How a request was matched to a variant, reported in pageinfo.
+const (
+	reasonDefault  = "default"
+	reasonPath     = "path"
+	reasonHeader   = "header"
+	reasonCookie   = "cookie"
+	reasonWeighted = "weighted"
+)
+
+// variantHeader selects a variant for requests to the root API. It is also
+// set on every response served by a variant. variantCookie does the same and
+// holds weighted assignments.
+const (
+	variantHeader = "X-Kuard-Variant"
+	variantCookie = "kuard-variant"
+)
+
+// defaultVariants are served when the config has no variants setting.
+func defaultVariants() []VariantConfig {
+	return []VariantConfig{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+}
+
+var variantName = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+func validateVariants(vcs []VariantConfig) error {
+	var errs []error
+	seen := map[string]bool{}
+	for i, vc := range vcs {
+		switch {
+		case !variantName.MatchString(vc.Name):
+			errs = append(errs, fmt.Errorf("variants[%d]: name %q must be lowercase letters, digits and dashes", i, vc.Name))
+		case seen[vc.Name]:
+			errs = append(errs, fmt.Errorf("variants[%d]: duplicate name %q", i, vc.Name))
+		}
+		seen[vc.Name] = true
+		if vc.Delay < 0 {
+			errs = append(errs, fmt.Errorf("variant %s: delay must not be negative", vc.Name))
+		}
+		if vc.ErrorRate < 0 || vc.ErrorRate > 1 {
+			errs = append(errs, fmt.Errorf("variant %s: error-rate must be between 0 and 1", vc.Name))
+		}
+		if vc.Weight < 0 {
+			errs = append(errs, fmt.Errorf("variant %s: weight must not be negative", vc.Name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// variant is a running variant with its own probes and KeyGen workload. The
+// root variant has an empty name.
+type variant struct {
+	name    string
+	prefix  string
+	color   string
+	version string
+	weight  int
+
+	live  *debugprobe.Probe
+	ready *debugprobe.Probe
+	kg    *keygen.KeyGen
+
+	// handlers holds what was registered for the variant, keyed by method
+	// and pattern without the prefix, so root requests can be served by it.
+	handlers map[string]http.Handler
+}
+
+func newVariant(vc VariantConfig) *variant {
+	v := &variant{
+		name:     vc.Name,
+		prefix:   "/" + vc.Name,
+		color:    vc.Color,
+		version:  vc.Version,
+		weight:   vc.Weight,
+		handlers: map[string]http.Handler{},
+		live:     debugprobe.New(),
+		ready:    debugprobe.New(),
+		kg:       keygen.New(),
+	}
+	if v.version == "" {
+		v.version = version.VERSION
+	}
+	if v.color == "" {
+		v.color = htmlutils.ColorFromString(vc.Name)
+	}
+	v.live.SetConfig(vc.Liveness)
+	v.ready.SetConfig(vc.Readiness)
+	if vc.KeyGen.Enable {
+		v.kg.LoadConfig(vc.KeyGen)
+	}
+	return v
+}
+
+// stop cancels the variant's KeyGen workload.
+func (v *variant) stop() {
+	v.kg.LoadConfig(keygen.Config{})
+}
+
+// selection records which variant serves a request and why.
+type selection struct {
+	variant string
+	reason  string
+}
+
+type selectionKey struct{}
+
+func withSelection(r *http.Request, s selection) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), selectionKey{}, s))
+}
+
+func selectionFrom(r *http.Request) (selection, bool) {
+	s, ok := r.Context().Value(selectionKey{}).(selection)
+	return s, ok
+}
+
+// variantSet picks the variant for requests to the root API.
+type variantSet struct {
+	byName   map[string]*variant
+	weighted []*variant
+	total    int
+}
+
+// add makes v selectable. Variants are added once, before serving.
+func (s *variantSet) add(variants ...*variant) {
+	if s.byName == nil {
+		s.byName = map[string]*variant{}
+	}
+	for _, v := range variants {
+		s.byName[v.name] = v
+		if v.weight > 0 {
+			s.weighted = append(s.weighted, v)
+			s.total += v.weight
+		}
+	}
+}
+
+// choose returns the variant requested by header or cookie, else a weighted
+// random one, else nil for the root. Unknown names are ignored.
+func (s *variantSet) choose(r *http.Request) (*variant, string) {
+	if v := s.byName[r.Header.Get(variantHeader)]; v != nil {
+		return v, reasonHeader
+	}
+	if c, err := r.Cookie(variantCookie); err == nil {
+		if v := s.byName[c.Value]; v != nil {
+			return v, reasonCookie
+		}
+	}
+	if s.total > 0 {
+		n := rand.IntN(s.total)
+		for _, v := range s.weighted {
+			if n < v.weight {
+				return v, reasonWeighted
+			}
+			n -= v.weight
+		}
+	}
+	return nil, reasonDefault
+}
+
+// selectRouter registers the root API, serving each request with the chosen
+// variant's handler for the same route when there is one.
+func selectRouter(router route.Router, set *variantSet) route.Router {
+	return route.Wrap(router, func(method, pattern string, h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			v, reason := set.choose(r)
+			if v != nil {
+				if vh := v.handlers[method+" "+pattern]; vh != nil {
+					if reason == reasonWeighted {
+						http.SetCookie(w, &http.Cookie{Name: variantCookie, Value: v.name, Path: "/", HttpOnly: true})
+					}
+					// Serve it as if it had been requested under the prefix.
+					vr := r.Clone(context.WithValue(r.Context(), selectionKey{}, selection{variant: v.name, reason: reason}))
+					vr.URL.Path = v.prefix + r.URL.Path
+					vr.URL.RawPath = ""
+					vh.ServeHTTP(w, vr)
+					return
+				}
+			}
+			h.ServeHTTP(w, withSelection(r, selection{reason: reasonDefault}))
+		})
+	})
+}
+
+// variantRouter registers v's routes. It records each handler for
+// selectRouter, marks requests that reach it by path and sets variantHeader
+// on the response.
+func variantRouter(router route.Router, v *variant) route.Router {
+	return route.Wrap(router, func(method, pattern string, h http.Handler) http.Handler {
+		out := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, ok := selectionFrom(r); !ok {
+				r = withSelection(r, selection{variant: v.name, reason: reasonPath})
+			}
+			w.Header().Set(variantHeader, v.name)
+			h.ServeHTTP(w, r)
+		})
+		v.handlers[method+" "+strings.TrimPrefix(pattern, v.prefix)] = out
+		return out
+	})
+}
+
+// variantFaultRules expresses each variant's delay and error rate as fault
+// rules. Failing requests are delayed too; the error rule comes first so
+// the delay rule only applies when it loses its roll.
+func variantFaultRules(vcs []VariantConfig) []faults.Rule {
+	var rules []faults.Rule
+	for _, vc := range vcs {
+		var latency *faults.Latency
+		if vc.Delay > 0 {
+			latency = &faults.Latency{Distribution: faults.DistFixed, Delay: vc.Delay}
+		}
+		if vc.ErrorRate > 0 {
+			rules = append(rules, faults.Rule{
+				ID:      "variant-" + vc.Name + "-errors",
+				Variant: vc.Name,
+				Percent: vc.ErrorRate * 100,
+				Status:  http.StatusInternalServerError,
+				Latency: latency,
+			})
+		}
+		if latency != nil {
+			rules = append(rules, faults.Rule{ID: "variant-" + vc.Name + "-delay", Variant: vc.Name, Latency: latency})
+		}
+	}
+	return rules
+}
@@ -0,0 +1,285 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDefaultVariants(t *testing.T) {
+	a := NewApp()
+	srv := httptest.NewServer(a.r)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/b/pageinfo")
+	if err != nil {
+		t.Fatalf("GET /b/pageinfo: %v", err)
+	}
+	var pc pageContext
+	json.NewDecoder(resp.Body).Decode(&pc)
+	if pc.Variant != "b" || pc.URLBase != "/b" {
+		t.Fatalf("unexpected pageinfo %+v", pc)
+	}
+}
+
+func TestConfiguredVariants(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "kuard.yaml")
+	writeFile(t, f, `
+variants:
+- name: blue
+  color: "#0000ff"
+  version: v2
+  readiness:
+    fail-next: -1
+- name: broken
+  delay: 5ms
+  error-rate: 1
+`)
+	a, _, err := loadTestConfig(t, f)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if err := a.CheckRoutes(); err != nil {
+		t.Fatalf("route conflicts: %v", err)
+	}
+	srv := httptest.NewServer(a.handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/blue/pageinfo")
+	if err != nil {
+		t.Fatalf("GET /blue/pageinfo: %v", err)
+	}
+	var pc pageContext
+	json.NewDecoder(resp.Body).Decode(&pc)
+	if pc.Variant != "blue" || pc.Version != "v2" || pc.VersionColor != "#0000ff" {
+		t.Fatalf("unexpected pageinfo %+v", pc)
+	}
+
+	start := time.Now()
+	resp, err = http.Get(srv.URL + "/broken/pageinfo")
+	if err != nil {
+		t.Fatalf("GET /broken/pageinfo: %v", err)
+	}
+	resp.Body.Close()
+	if d := time.Since(start); d < 5*time.Millisecond || resp.Header.Get("X-Kuard-Fault") != "variant-broken-errors" {
+		t.Fatalf("broken variant: %v, fault %q", d, resp.Header.Get("X-Kuard-Fault"))
+	}
+
+	for path, want := range map[string]int{
+		"/blue/ready":      http.StatusInternalServerError,
+		"/ready":           http.StatusOK,
+		"/broken/pageinfo": http.StatusInternalServerError,
+		"/a/pageinfo":      http.StatusNotFound,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		if resp.StatusCode != want {
+			t.Fatalf("%s status %d, want %d", path, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestInvalidVariants(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"reserved.yaml":  "variants:\n- name: mem\n",
+		"config.yaml":    "variants:\n- name: config\n",
+		"faults.yaml":    "variants:\n- name: faults\n",
+		"routes.yaml":    "variants:\n- name: routes\n",
+		"duplicate.yaml": "variants:\n- name: x\n- name: x\n",
+		"badname.yaml":   "variants:\n- name: Bad/Name\n",
+		"rate.yaml":      "variants:\n- name: x\n  error-rate: 2\n",
+	} {
+		f := filepath.Join(dir, name)
+		writeFile(t, f, data)
+		if _, _, err := loadTestConfig(t, f); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestVariantSelection(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "kuard.yaml")
+	writeFile(t, f, `
+variants:
+- name: stable
+  version: v1
+- name: canary
+  version: v2
+  weight: 1
+`)
+	a, _, err := loadTestConfig(t, f)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	srv := httptest.NewServer(a.handler())
+	defer srv.Close()
+
+	pageinfo := func(path string, setup func(*http.Request)) (pageContext, *http.Response) {
+		t.Helper()
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		setup(req)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+		var pc pageContext
+		if err := json.NewDecoder(resp.Body).Decode(&pc); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return pc, resp
+	}
+
+	cases := []struct {
+		name        string
+		path        string
+		setup       func(*http.Request)
+		wantVariant string
+		wantReason  string
+	}{
+		{"path", "/stable/pageinfo", func(*http.Request) {}, "stable", reasonPath},
+		{"header", "/pageinfo", func(r *http.Request) { r.Header.Set(variantHeader, "stable") }, "stable", reasonHeader},
+		{"cookie", "/pageinfo", func(r *http.Request) { r.AddCookie(&http.Cookie{Name: variantCookie, Value: "stable"}) }, "stable", reasonCookie},
+		{"header beats cookie", "/pageinfo", func(r *http.Request) {
+			r.Header.Set(variantHeader, "canary")
+			r.AddCookie(&http.Cookie{Name: variantCookie, Value: "stable"})
+		}, "canary", reasonHeader},
+		{"unknown header", "/pageinfo", func(r *http.Request) { r.Header.Set(variantHeader, "nope") }, "canary", reasonWeighted},
+		{"weighted", "/pageinfo", func(*http.Request) {}, "canary", reasonWeighted},
+	}
+	for _, c := range cases {
+		pc, resp := pageinfo(c.path, c.setup)
+		if pc.Variant != c.wantVariant || pc.VariantReason != c.wantReason {
+			t.Fatalf("%s: served by %q (%s), want %q (%s)", c.name, pc.Variant, pc.VariantReason, c.wantVariant, c.wantReason)
+		}
+		if got := resp.Header.Get(variantHeader); got != c.wantVariant {
+			t.Fatalf("%s: %s header %q", c.name, variantHeader, got)
+		}
+		sticky := false
+		for _, ck := range resp.Cookies() {
+			sticky = sticky || (ck.Name == variantCookie && ck.Value == "canary")
+		}
+		if sticky != (c.wantReason == reasonWeighted) {
+			t.Fatalf("%s: sticky cookie set=%v", c.name, sticky)
+		}
+	}
+
+	// Without weights the root serves requests itself.
+	a2 := NewApp()
+	srv2 := httptest.NewServer(a2.handler())
+	defer srv2.Close()
+	resp, err := http.Get(srv2.URL + "/pageinfo")
+	if err != nil {
+		t.Fatalf("GET /pageinfo: %v", err)
+	}
+	var pc pageContext
+	json.NewDecoder(resp.Body).Decode(&pc)
+	if pc.Variant != "" || pc.VariantReason != reasonDefault {
+		t.Fatalf("root served by %q (%s)", pc.Variant, pc.VariantReason)
+	}
+}
+
+func TestRootProbesIgnoreSelection(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "kuard.yaml")
+	writeFile(t, f, `
+variants:
+- name: canary
+  weight: 1
+  readiness:
+    fail-next: -1
+  liveness:
+    fail-next: -1
+`)
+	a, _, err := loadTestConfig(t, f)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	srv := httptest.NewServer(a.handler())
+	defer srv.Close()
+
+	for path, want := range map[string]int{
+		"/ready":          http.StatusOK,
+		"/healthy":        http.StatusOK,
+		"/canary/ready":   http.StatusInternalServerError,
+		"/canary/healthy": http.StatusInternalServerError,
+	} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		req.Header.Set(variantHeader, "canary")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != want {
+			t.Fatalf("%s status %d, want %d", path, resp.StatusCode, want)
+		}
+		if v := resp.Header.Get(variantHeader); path == "/ready" && v != "" {
+			t.Fatalf("root /ready served by variant %q", v)
+		}
+	}
+}
+
+func TestFaultRules(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "kuard.yaml")
+	writeFile(t, f, `
+faults:
+- id: canary-down
+  variant: b
+  route: /*/pageinfo
+  status: 502
+- id: root-pageinfo
+  route: /pageinfo
+  status: 503
+- id: slow-env
+  route: /env/api
+  latency:
+    distribution: fixed
+    delay: 1ms
+`)
+	a, _, err := loadTestConfig(t, f)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	srv := httptest.NewServer(a.handler())
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		path, variant string
+		want          int
+	}{
+		{"/a/pageinfo", "", 200},
+		{"/b/pageinfo", "", 502},
+		{"/pageinfo", "", 503},
+		// Rules match the requested route, whichever variant serves it.
+		{"/pageinfo", "b", 503},
+		{"/pageinfo", "a", 503},
+		{"/env/api", "", 200},
+	} {
+		req, _ := http.NewRequest("GET", srv.URL+tc.path, nil)
+		if tc.variant != "" {
+			req.Header.Set(variantHeader, tc.variant)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %s: %v", tc.path, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.want {
+			t.Fatalf("GET %s variant %q: %d, want %d", tc.path, tc.variant, resp.StatusCode, tc.want)
+		}
+	}
+	if got := a.faults.Rules(); len(got) != 3 || got[2].Latency.Delay != time.Millisecond {
+		t.Fatalf("rules from config %+v", got)
+	}
+
+	writeFile(t, f, "faults:\n- status: 42\n")
+	if _, _, err := loadTestConfig(t, f); err == nil {
+		t.Fatalf("expected error for invalid fault rule")
+	}
+}
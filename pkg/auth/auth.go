@@ -0,0 +1,226 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package auth protects routes with a bearer token, htpasswd basic auth or a
+// verified client certificate, and can put the server in read-only mode.
+// Protection is decided per route when it is registered, from its subsystem
+// and method.
+package auth
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+// Authenticator checks one kind of credential.
+type Authenticator interface {
+	// Authenticate returns the user a request's credential identifies.
+	Authenticate(r *http.Request) (user string, ok bool)
+}
+
+// Auth applies a Config to routes.
+type Auth struct {
+	methods    []Authenticator
+	challenges []string // WWW-Authenticate values
+	subsystems map[string]bool
+	readOnly   bool
+}
+
+// New loads the token and htpasswd files named by c.
+func New(c Config) (*Auth, error) {
+	a := &Auth{subsystems: map[string]bool{}, readOnly: c.ReadOnly}
+	for _, s := range c.Subsystems {
+		a.subsystems[s] = true
+	}
+
+	token := c.Token
+	if c.TokenFile != "" {
+		b, err := os.ReadFile(c.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("auth token file: %w", err)
+		}
+		token = strings.TrimSpace(string(b))
+		if token == "" {
+			return nil, fmt.Errorf("auth token file %s is empty", c.TokenFile)
+		}
+	}
+	if token != "" {
+		a.methods = append(a.methods, bearerToken(token))
+		a.challenges = append(a.challenges, `Bearer realm="kuard"`)
+	}
+	if c.Htpasswd != "" {
+		h, err := loadHtpasswd(c.Htpasswd)
+		if err != nil {
+			return nil, fmt.Errorf("auth htpasswd: %w", err)
+		}
+		a.methods = append(a.methods, h)
+		a.challenges = append(a.challenges, `Basic realm="kuard"`)
+	}
+	if c.ClientCert {
+		a.methods = append(a.methods, clientCert{})
+	}
+	return a, nil
+}
+
+// Enabled reports whether any authenticator was built, so credentials are
+// checked at all.
+func (a *Auth) Enabled() bool {
+	return len(a.methods) > 0
+}
+
+// Protects reports whether a route needs credentials.
+func (a *Auth) Protects(method string, m route.Meta) bool {
+	return a.Enabled() && (mutating(method, m) || a.subsystems["*"] || a.subsystems[m.Subsystem])
+}
+
+// mutating reports whether a route can change state: any method other than
+// GET, HEAD and OPTIONS, unless the route is marked ReadOnly.
+func mutating(method string, m route.Meta) bool {
+	if m.ReadOnly {
+		return false
+	}
+	return method != http.MethodGet && method != http.MethodHead && method != http.MethodOptions
+}
+
+func (a *Auth) authenticate(r *http.Request) (string, bool) {
+	for _, m := range a.methods {
+		if user, ok := m.Authenticate(r); ok {
+			return user, true
+		}
+	}
+	return "", false
+}
+
+type userKey struct{}
+
+// UserFromContext returns the user authenticated for a protected route.
+func UserFromContext(ctx context.Context) (string, bool) {
+	u, ok := ctx.Value(userKey{}).(string)
+	return u, ok
+}
+
+// Router wraps r so routes registered through it are protected according to
+// a. Register routes with their subsystem already tagged.
+func (a *Auth) Router(r route.Router) route.Router {
+	return route.Wrap(r, func(method, _ string, h http.Handler) http.Handler {
+		return a.wrap(method, h)
+	})
+}
+
+func (a *Auth) wrap(method string, h http.Handler) http.Handler {
+	m, _ := route.MetaOf(h)
+	switch {
+	case a.readOnly && mutating(method, m):
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "read-only mode", http.StatusForbidden)
+		})
+	case a.Protects(method, m):
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user, ok := a.authenticate(r)
+			if !ok {
+				for _, c := range a.challenges {
+					w.Header().Add("WWW-Authenticate", c)
+				}
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userKey{}, user)))
+		})
+	}
+	return h
+}
+
+// bearerToken accepts "Authorization: Bearer <token>".
+type bearerToken string
+
+func (t bearerToken) Authenticate(r *http.Request) (string, bool) {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(t)) != 1 {
+		return "", false
+	}
+	return "token", true
+}
+
+// htpasswd accepts basic auth against bcrypt or {SHA} hashes.
+type htpasswd map[string]string
+
+func loadHtpasswd(path string) (htpasswd, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := htpasswd{}
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		user, hash, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("malformed line for %q", user)
+		}
+		if !strings.HasPrefix(hash, "$2") && !strings.HasPrefix(hash, "{SHA}") {
+			return nil, fmt.Errorf("user %q: only bcrypt and {SHA} hashes are supported", user)
+		}
+		h[user] = hash
+	}
+	return h, sc.Err()
+}
+
+func (h htpasswd) Authenticate(r *http.Request) (string, bool) {
+	user, pass, ok := r.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	hash, ok := h[user]
+	if !ok {
+		return "", false
+	}
+	if sha, isSHA := strings.CutPrefix(hash, "{SHA}"); isSHA {
+		sum := sha1.Sum([]byte(pass))
+		ok = subtle.ConstantTimeCompare([]byte(base64.StdEncoding.EncodeToString(sum[:])), []byte(sha)) == 1
+	} else {
+		ok = bcrypt.CompareHashAndPassword([]byte(hash), []byte(pass)) == nil
+	}
+	if !ok {
+		return "", false
+	}
+	return user, true
+}
+
+// clientCert accepts a client certificate the TLS handshake verified.
+type clientCert struct{}
+
+func (clientCert) Authenticate(r *http.Request) (string, bool) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		return "", false
+	}
+	return r.TLS.VerifiedChains[0][0].Subject.CommonName, true
+}
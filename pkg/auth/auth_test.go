@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+// testRouter serves whatever was last registered for a method.
+type testRouter map[string]http.Handler
+
+func (t testRouter) GET(_ string, h http.Handler)    { t[http.MethodGet] = h }
+func (t testRouter) POST(_ string, h http.Handler)   { t[http.MethodPost] = h }
+func (t testRouter) PUT(_ string, h http.Handler)    { t[http.MethodPut] = h }
+func (t testRouter) PATCH(_ string, h http.Handler)  { t[http.MethodPatch] = h }
+func (t testRouter) DELETE(_ string, h http.Handler) { t[http.MethodDelete] = h }
+
+var ok = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	user, _ := UserFromContext(r.Context())
+	w.Header().Set("X-User", user)
+})
+
+func serve(t *testing.T, a *Auth, method, subsystem string, setup func(*http.Request)) *httptest.ResponseRecorder {
+	t.Helper()
+	tr := testRouter{}
+	r := route.WithSubsystem(a.Router(tr), subsystem)
+	switch method {
+	case http.MethodGet:
+		r.GET("/", ok)
+	case http.MethodPut:
+		r.PUT("/", ok)
+	case http.MethodPost:
+		r.POST("/", route.Describe(ok, route.Meta{ReadOnly: true}))
+	}
+	req := httptest.NewRequest(method, "/", nil)
+	setup(req)
+	w := httptest.NewRecorder()
+	tr[method].ServeHTTP(w, req)
+	return w
+}
+
+func none(*http.Request) {}
+
+func TestToken(t *testing.T) {
+	a, err := New(Config{Token: "s3cret", Subsystems: DefaultSubsystems})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	bearer := func(tok string) func(*http.Request) {
+		return func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+tok) }
+	}
+	cases := []struct {
+		method, subsystem string
+		setup             func(*http.Request)
+		want              int
+	}{
+		{http.MethodGet, "env", none, http.StatusUnauthorized},
+		{http.MethodGet, "env", bearer("wrong"), http.StatusUnauthorized},
+		{http.MethodGet, "env", bearer("s3cret"), http.StatusOK},
+		{http.MethodGet, "mem", none, http.StatusOK},
+		{http.MethodPut, "readiness", none, http.StatusUnauthorized},
+		{http.MethodPut, "readiness", bearer("s3cret"), http.StatusOK},
+	}
+	for _, c := range cases {
+		w := serve(t, a, c.method, c.subsystem, c.setup)
+		if w.Code != c.want {
+			t.Fatalf("%s %s: status %d, want %d", c.method, c.subsystem, w.Code, c.want)
+		}
+		if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != `Bearer realm="kuard"` {
+			t.Fatalf("missing challenge: %v", w.Header())
+		}
+	}
+}
+
+func TestTokenFile(t *testing.T) {
+	f := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(f, []byte("s3cret\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	a, err := New(Config{TokenFile: f})
+	if err != nil || !a.Enabled() {
+		t.Fatalf("New: %v, enabled %v", err, a != nil && a.Enabled())
+	}
+
+	// An empty token would leave everything open.
+	if err := os.WriteFile(f, []byte(" \n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := New(Config{TokenFile: f}); err == nil {
+		t.Fatalf("expected error for an empty token file")
+	}
+}
+
+func TestHtpasswd(t *testing.T) {
+	bc, _ := bcrypt.GenerateFromPassword([]byte("pw1"), bcrypt.MinCost)
+	sum := sha1.Sum([]byte("pw2"))
+	f := filepath.Join(t.TempDir(), "htpasswd")
+	data := "# users\nalice:" + string(bc) + "\nbob:{SHA}" + base64.StdEncoding.EncodeToString(sum[:]) + "\n"
+	if err := os.WriteFile(f, []byte(data), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	a, err := New(Config{Htpasswd: f, Subsystems: []string{"*"}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, c := range []struct {
+		user, pass string
+		want       int
+	}{
+		{"alice", "pw1", http.StatusOK},
+		{"bob", "pw2", http.StatusOK},
+		{"alice", "pw2", http.StatusUnauthorized},
+		{"carol", "pw1", http.StatusUnauthorized},
+	} {
+		w := serve(t, a, http.MethodGet, "mem", func(r *http.Request) { r.SetBasicAuth(c.user, c.pass) })
+		if w.Code != c.want {
+			t.Fatalf("%s: status %d, want %d", c.user, w.Code, c.want)
+		}
+		if w.Code == http.StatusOK && w.Header().Get("X-User") != c.user {
+			t.Fatalf("user not in context: %q", w.Header().Get("X-User"))
+		}
+	}
+
+	if err := os.WriteFile(f, []byte("eve:$apr1$abc$def\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := New(Config{Htpasswd: f}); err == nil {
+		t.Fatalf("expected error for unsupported hash")
+	}
+}
+
+func TestClientCert(t *testing.T) {
+	a, _ := New(Config{ClientCert: true, Subsystems: []string{"env"}})
+	w := serve(t, a, http.MethodGet, "env", func(r *http.Request) {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: "client-1"}}
+		r.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+	})
+	if w.Code != http.StatusOK || w.Header().Get("X-User") != "client-1" {
+		t.Fatalf("status %d user %q", w.Code, w.Header().Get("X-User"))
+	}
+	if w := serve(t, a, http.MethodGet, "env", none); w.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d without cert", w.Code)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	a, _ := New(Config{ReadOnly: true})
+	if w := serve(t, a, http.MethodPut, "keygen", none); w.Code != http.StatusForbidden {
+		t.Fatalf("PUT status %d in read-only mode", w.Code)
+	}
+	if w := serve(t, a, http.MethodGet, "keygen", none); w.Code != http.StatusOK {
+		t.Fatalf("GET status %d in read-only mode", w.Code)
+	}
+	// The DNS lookup is a POST but changes nothing.
+	if w := serve(t, a, http.MethodPost, "dns", none); w.Code != http.StatusOK {
+		t.Fatalf("read-only POST status %d in read-only mode", w.Code)
+	}
+
+	a, _ = New(Config{Token: "s3cret", ReadOnly: true})
+	if w := serve(t, a, http.MethodPost, "dns", none); w.Code != http.StatusOK {
+		t.Fatalf("read-only POST status %d without credentials", w.Code)
+	}
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package auth
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Config selects the accepted credentials and what they protect. Auth is
+// enabled when at least one credential type is configured.
+type Config struct {
+	// Token is a static bearer token. TokenFile holds one instead, e.g. from
+	// a Secret volume.
+	Token     string `json:"token"`
+	TokenFile string `json:"tokenFile" mapstructure:"token-file"`
+
+	// Htpasswd is an htpasswd file of bcrypt or {SHA} entries for basic auth.
+	Htpasswd string `json:"htpasswd"`
+
+	// ClientCert accepts a client certificate verified against
+	// --tls-client-ca as a credential.
+	ClientCert bool `json:"clientCert" mapstructure:"client-cert"`
+
+	// Subsystems lists the subsystems whose routes need credentials, or "*"
+	// for all. Mutating routes always need them.
+	Subsystems []string `json:"subsystems"`
+
+	// ReadOnly rejects every mutating route, authenticated or not.
+	ReadOnly bool `json:"readOnly" mapstructure:"read-only"`
+}
+
+// DefaultSubsystems expose files, environment and config.
+var DefaultSubsystems = []string{"env", "fs", "fsapi", "config"}
+
+func BindConfig(v *viper.Viper, fs *pflag.FlagSet) {
+	fs.String("auth-token", "", "Static bearer token accepted for protected routes")
+	fs.String("auth-token-file", "", "File holding the bearer token")
+	fs.String("auth-htpasswd", "", "htpasswd file (bcrypt or {SHA}) for basic auth on protected routes")
+	fs.Bool("auth-client-cert", false, "Accept a verified client certificate as a credential")
+	fs.StringSlice("auth-subsystems", DefaultSubsystems, "Subsystems that need credentials, or * for all. Mutating routes always do.")
+	fs.Bool("auth-read-only", false, "Reject every mutating route")
+
+	fs.VisitAll(func(f *pflag.Flag) {
+		name := strings.TrimPrefix(f.Name, "auth-")
+		if name != f.Name {
+			v.BindPFlag("auth."+name, f)
+		}
+	})
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package certs serves the HTTPS certificate from a directory and reloads it
+// when the files change, including the atomic ..data symlink swap Kubernetes
+// uses for Secret volumes. It can also generate a self-signed certificate
+// when none has been provisioned.
+package certs
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/dirwatch"
+)
+
+// File names looked up in the certificate directory.
+const (
+	CertFile = "kuard.crt"
+	KeyFile  = "kuard.key"
+)
+
+var reloads = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "kuard",
+	Subsystem: "tls",
+	Name:      "reloads_total",
+	Help:      "TLS certificate reload attempts by result.",
+}, []string{"result"})
+
+func init() {
+	prometheus.MustRegister(reloads)
+}
+
+// Exist reports whether both certificate files are present in dir.
+func Exist(dir string) bool {
+	for _, f := range []string{CertFile, KeyFile} {
+		if _, err := os.Stat(filepath.Join(dir, f)); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// Reloader holds the current certificate for a directory.
+type Reloader struct {
+	dir string
+
+	mu   sync.RWMutex
+	cert *tls.Certificate
+}
+
+func NewReloader(dir string) *Reloader {
+	return &Reloader{dir: dir}
+}
+
+// Load reads the certificate files. On error the current certificate is kept.
+func (r *Reloader) Load() error {
+	c, err := tls.LoadX509KeyPair(filepath.Join(r.dir, CertFile), filepath.Join(r.dir, KeyFile))
+	if err != nil {
+		reloads.WithLabelValues("error").Inc()
+		return err
+	}
+	reloads.WithLabelValues("success").Inc()
+
+	r.mu.Lock()
+	changed := r.cert == nil || !bytes.Equal(r.cert.Certificate[0], c.Certificate[0])
+	r.cert = &c
+	r.mu.Unlock()
+
+	if changed {
+		slog.Info("tls certificate loaded", "dir", r.dir, "subject", c.Leaf.Subject.String(), "notAfter", c.Leaf.NotAfter)
+	}
+	return nil
+}
+
+// SetCertificate replaces the current certificate, e.g. with a self-signed
+// one when the directory is empty.
+func (r *Reloader) SetCertificate(c *tls.Certificate) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = c
+}
+
+// Certificate returns the current certificate, or nil.
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// GetCertificate implements tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c := r.Certificate()
+	if c == nil {
+		return nil, errors.New("no tls certificate loaded")
+	}
+	return c, nil
+}
+
+// Watch reloads the certificate whenever the directory changes, until ctx is
+// cancelled.
+func (r *Reloader) Watch(ctx context.Context) error {
+	return dirwatch.Watch(ctx, r.dir, func() {
+		if !Exist(r.dir) {
+			return
+		}
+		if err := r.Load(); err != nil {
+			slog.Warn("tls certificate reload failed; keeping previous", "dir", r.dir, "error", err)
+		}
+	})
+}
+
+// SelfSigned generates a certificate valid for hosts, which may be DNS names
+// or IP addresses.
+func SelfSigned(hosts []string) (*tls.Certificate, error) {
+	certPEM, keyPEM, err := selfSignedPEM(hosts, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	c, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func selfSignedPEM(hosts []string, now time.Time) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"kuard self-signed"}},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if h != "" {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	if len(hosts) > 0 {
+		tmpl.Subject.CommonName = hosts[0]
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// LocalHosts returns the hostname, localhost and every interface address,
+// which covers the pod IPs (IPv4 and IPv6) inside a pod.
+func LocalHosts() []string {
+	hosts := []string{}
+	if h, err := os.Hostname(); err == nil {
+		hosts = append(hosts, h)
+	}
+	hosts = append(hosts, "localhost")
+	addrs, _ := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok {
+			hosts = append(hosts, ipnet.IP.String())
+		}
+	}
+	return hosts
+}
@@ -0,0 +1,78 @@
+package certs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSecretVolume lays out dir like a Kubernetes Secret volume: the files
+// are symlinks through ..data, which points at a timestamped directory.
+func writeSecretVolume(t *testing.T, dir, version, host string) {
+	t.Helper()
+	certPEM, keyPEM, err := selfSignedPEM([]string{host}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := filepath.Join(dir, "..data_"+version)
+	if err := os.Mkdir(data, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(data, CertFile), certPEM, 0o644)
+	os.WriteFile(filepath.Join(data, KeyFile), keyPEM, 0o600)
+
+	tmp := filepath.Join(dir, "..data_tmp")
+	if err := os.Symlink("..data_"+version, tmp); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, "..data")); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{CertFile, KeyFile} {
+		os.Symlink(filepath.Join("..data", f), filepath.Join(dir, f))
+	}
+}
+
+func TestReloaderWatchesSymlinkSwap(t *testing.T) {
+	dir := t.TempDir()
+	writeSecretVolume(t, dir, "1", "first.example")
+
+	r := NewReloader(dir)
+	if err := r.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got := r.Certificate().Leaf.Subject.CommonName; got != "first.example" {
+		t.Fatalf("unexpected cert %s", got)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Watch(ctx)
+	time.Sleep(100 * time.Millisecond)
+
+	writeSecretVolume(t, dir, "2", "second.example")
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c, err := r.GetCertificate(nil)
+		if err == nil && c.Leaf.Subject.CommonName == "second.example" {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("rotated cert not picked up")
+}
+
+func TestSelfSigned(t *testing.T) {
+	c, err := SelfSigned([]string{"kuard", "10.0.0.1", "fd00::1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Leaf.DNSNames) != 1 || len(c.Leaf.IPAddresses) != 2 {
+		t.Fatalf("unexpected SANs %v %v", c.Leaf.DNSNames, c.Leaf.IPAddresses)
+	}
+	if err := c.Leaf.VerifyHostname("10.0.0.1"); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+}
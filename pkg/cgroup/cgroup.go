@@ -0,0 +1,118 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cgroup reads the container's resource limits and usage from the
+// cgroup filesystem, as the kubelet sees them. cgroup v2 is preferred, with a
+// fallback to the v1 controller hierarchies.
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultRoot is where the cgroup filesystem is mounted in a container.
+const DefaultRoot = "/sys/fs/cgroup"
+
+// ErrNotFound is returned when no cgroup files are found under the root.
+var ErrNotFound = errors.New("cgroup: no controller files found")
+
+// Reader reads cgroup files under a root directory. A fake root lets tests
+// lay out either hierarchy.
+type Reader struct {
+	Root string
+}
+
+func New(root string) *Reader {
+	return &Reader{Root: root}
+}
+
+// Version returns 2 for a unified hierarchy, 1 for per-controller
+// directories, or 0 if neither is found.
+func (r *Reader) Version() int {
+	if exists(filepath.Join(r.Root, "cgroup.controllers")) {
+		return 2
+	}
+	if exists(filepath.Join(r.Root, "cpu")) || exists(filepath.Join(r.Root, "memory")) {
+		return 1
+	}
+	return 0
+}
+
+func (r *Reader) path(elem ...string) string {
+	return filepath.Join(append([]string{r.Root}, elem...)...)
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// readInt reads a file holding a single number. "max" reads as -1, as do
+// the v1 files that use -1 or a huge value for no limit.
+func readInt(path string) (int64, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return parseInt(strings.TrimSpace(string(b)))
+}
+
+func parseInt(s string) (int64, error) {
+	if s == "max" {
+		return -1, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		// v1 memory.limit_in_bytes reports no limit as a page-aligned
+		// value near MaxInt64, which may not parse as int64.
+		if u, uerr := strconv.ParseUint(s, 10, 64); uerr == nil && u > 1<<62 {
+			return -1, nil
+		}
+		return 0, err
+	}
+	if n > 1<<62 {
+		return -1, nil
+	}
+	return n, nil
+}
+
+// readKeyed reads a flat keyed file such as cpu.stat, one "key value" pair
+// per line.
+func readKeyed(path string) (map[string]int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	out := map[string]int64{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		k, v, ok := strings.Cut(s.Text(), " ")
+		if !ok {
+			continue
+		}
+		if n, err := parseInt(strings.TrimSpace(v)); err == nil {
+			out[k] = n
+		}
+	}
+	return out, s.Err()
+}
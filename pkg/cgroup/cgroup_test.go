@@ -0,0 +1,152 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeRoot lays out files, keyed by path relative to the root.
+func fakeRoot(t *testing.T, files map[string]string) *Reader {
+	t.Helper()
+	root := t.TempDir()
+	for name, data := range files {
+		p := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return New(root)
+}
+
+func TestCPUV2(t *testing.T) {
+	r := fakeRoot(t, map[string]string{
+		"cgroup.controllers": "cpu memory pids\n",
+		"cpu.max":            "50000 100000\n",
+		"cpu.stat":           "usage_usec 2000\nuser_usec 1500\nsystem_usec 500\nnr_periods 10\nnr_throttled 4\nthrottled_usec 800\n",
+	})
+	if v := r.Version(); v != 2 {
+		t.Fatalf("version %d, want 2", v)
+	}
+	c, err := r.CPU()
+	if err != nil {
+		t.Fatalf("CPU: %v", err)
+	}
+	want := CPU{QuotaUsec: 50000, PeriodUsec: 100000, LimitMillicores: 500, UsageUsec: 2000, NrPeriods: 10, NrThrottled: 4, ThrottledUsec: 800}
+	if *c != want {
+		t.Fatalf("got %+v, want %+v", *c, want)
+	}
+}
+
+func TestCPUV2Unlimited(t *testing.T) {
+	r := fakeRoot(t, map[string]string{
+		"cgroup.controllers": "cpu\n",
+		"cpu.max":            "max 100000\n",
+		"cpu.stat":           "usage_usec 1\n",
+	})
+	c, err := r.CPU()
+	if err != nil {
+		t.Fatalf("CPU: %v", err)
+	}
+	if c.QuotaUsec != -1 || c.LimitMillicores != 0 {
+		t.Fatalf("unlimited cpu.max read as %+v", *c)
+	}
+}
+
+func TestCPUV1(t *testing.T) {
+	r := fakeRoot(t, map[string]string{
+		"cpu/cpu.cfs_quota_us":         "150000\n",
+		"cpu/cpu.cfs_period_us":        "100000\n",
+		"cpu/cpu.stat":                 "nr_periods 7\nnr_throttled 3\nthrottled_time 5000000\n",
+		"cpuacct/cpuacct.usage":        "9000000\n",
+		"memory/memory.usage_in_bytes": "1\n",
+	})
+	if v := r.Version(); v != 1 {
+		t.Fatalf("version %d, want 1", v)
+	}
+	c, err := r.CPU()
+	if err != nil {
+		t.Fatalf("CPU: %v", err)
+	}
+	want := CPU{QuotaUsec: 150000, PeriodUsec: 100000, LimitMillicores: 1500, UsageUsec: 9000, NrPeriods: 7, NrThrottled: 3, ThrottledUsec: 5000}
+	if *c != want {
+		t.Fatalf("got %+v, want %+v", *c, want)
+	}
+}
+
+func TestNoCgroup(t *testing.T) {
+	if _, err := New(t.TempDir()).CPU(); err != ErrNotFound {
+		t.Fatalf("got %v, want ErrNotFound", err)
+	}
+}
+
+func TestStatsV2(t *testing.T) {
+	r := fakeRoot(t, map[string]string{
+		"cgroup.controllers": "cpu memory pids\n",
+		"memory.current":     "104857600\n",
+		"memory.max":         "268435456\n",
+		"memory.events":      "low 0\nhigh 0\nmax 12\noom 2\noom_kill 1\n",
+		"memory.stat":        "anon 50000000\nfile 40000000\nshmem 1000\ninactive_file 4857600\n",
+		"pids.current":       "7\n",
+		"pids.max":           "max\n",
+	})
+	s, err := r.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	m := s.Memory
+	if s.Version != 2 || m.UsageBytes != 104857600 || m.LimitBytes != 268435456 || m.WorkingSetBytes != 100000000 {
+		t.Fatalf("memory %+v", m)
+	}
+	if m.Events != (MemoryEvents{Max: 12, OOM: 2, OOMKill: 1}) || m.Stat["anon"] != 50000000 {
+		t.Fatalf("events %+v stat %v", m.Events, m.Stat)
+	}
+	if *s.Pids != (Pids{Current: 7, Max: -1}) {
+		t.Fatalf("pids %+v", *s.Pids)
+	}
+	// No cpu.stat in this root.
+	if s.CPU != nil {
+		t.Fatalf("cpu %+v", *s.CPU)
+	}
+}
+
+func TestStatsV1(t *testing.T) {
+	r := fakeRoot(t, map[string]string{
+		"memory/memory.usage_in_bytes": "2000000\n",
+		"memory/memory.limit_in_bytes": "9223372036854771712\n",
+		"memory/memory.oom_control":    "oom_kill_disable 0\nunder_oom 0\noom_kill 3\n",
+		"memory/memory.stat":           "rss 1000\ncache 500\ntotal_inactive_file 500000\n",
+		"pids/pids.current":            "12\n",
+		"pids/pids.max":                "100\n",
+	})
+	s, err := r.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	m := s.Memory
+	if s.Version != 1 || m.LimitBytes != -1 || m.WorkingSetBytes != 1500000 || m.Events.OOMKill != 3 || m.Stat["rss"] != 1000 {
+		t.Fatalf("memory %+v", m)
+	}
+	if *s.Pids != (Pids{Current: 12, Max: 100}) {
+		t.Fatalf("pids %+v", *s.Pids)
+	}
+}
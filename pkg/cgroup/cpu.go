@@ -0,0 +1,105 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"os"
+	"strings"
+)
+
+// CPU is the cgroup's CPU limit, usage and throttling. Times are in
+// microseconds, as in cgroup v2; v1 values are converted.
+type CPU struct {
+	// QuotaUsec is the runtime allowed per period, or -1 if unlimited.
+	QuotaUsec  int64 `json:"quotaUsec"`
+	PeriodUsec int64 `json:"periodUsec"`
+	// LimitMillicores is the quota as a Kubernetes CPU limit, or 0 if
+	// unlimited.
+	LimitMillicores int64 `json:"limitMillicores"`
+
+	UsageUsec     int64 `json:"usageUsec"`
+	NrPeriods     int64 `json:"nrPeriods"`
+	NrThrottled   int64 `json:"nrThrottled"`
+	ThrottledUsec int64 `json:"throttledUsec"`
+}
+
+// CPU reads the CPU controller.
+func (r *Reader) CPU() (*CPU, error) {
+	switch r.Version() {
+	case 2:
+		return r.cpuV2()
+	case 1:
+		return r.cpuV1()
+	}
+	return nil, ErrNotFound
+}
+
+func (r *Reader) cpuV2() (*CPU, error) {
+	stat, err := readKeyed(r.path("cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	c := &CPU{
+		QuotaUsec:     -1,
+		PeriodUsec:    100000,
+		UsageUsec:     stat["usage_usec"],
+		NrPeriods:     stat["nr_periods"],
+		NrThrottled:   stat["nr_throttled"],
+		ThrottledUsec: stat["throttled_usec"],
+	}
+	// cpu.max is "$MAX $PERIOD". The root cgroup has none.
+	if b, err := os.ReadFile(r.path("cpu.max")); err == nil {
+		fields := strings.Fields(string(b))
+		if len(fields) == 2 {
+			c.QuotaUsec, _ = parseInt(fields[0])
+			c.PeriodUsec, _ = parseInt(fields[1])
+		}
+	}
+	c.setLimit()
+	return c, nil
+}
+
+func (r *Reader) cpuV1() (*CPU, error) {
+	stat, err := readKeyed(r.path("cpu", "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	c := &CPU{
+		QuotaUsec:     -1,
+		PeriodUsec:    100000,
+		NrPeriods:     stat["nr_periods"],
+		NrThrottled:   stat["nr_throttled"],
+		ThrottledUsec: stat["throttled_time"] / 1000,
+	}
+	if n, err := readInt(r.path("cpu", "cpu.cfs_quota_us")); err == nil {
+		c.QuotaUsec = n
+	}
+	if n, err := readInt(r.path("cpu", "cpu.cfs_period_us")); err == nil {
+		c.PeriodUsec = n
+	}
+	if n, err := readInt(r.path("cpuacct", "cpuacct.usage")); err == nil {
+		c.UsageUsec = n / 1000
+	}
+	c.setLimit()
+	return c, nil
+}
+
+func (c *CPU) setLimit() {
+	if c.QuotaUsec > 0 && c.PeriodUsec > 0 {
+		c.LimitMillicores = c.QuotaUsec * 1000 / c.PeriodUsec
+	}
+}
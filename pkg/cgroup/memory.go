@@ -0,0 +1,89 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+// Memory is the cgroup's memory limit and usage in bytes.
+type Memory struct {
+	UsageBytes int64 `json:"usageBytes"`
+	// LimitBytes is -1 if unlimited.
+	LimitBytes int64 `json:"limitBytes"`
+	// WorkingSetBytes is usage less inactive file cache. The kubelet evicts
+	// and reports container memory on this figure.
+	WorkingSetBytes int64 `json:"workingSetBytes"`
+
+	Events MemoryEvents `json:"events"`
+	// Stat is the memory.stat breakdown: anon, file, shmem and so on in v2,
+	// rss, cache, shmem and so on in v1.
+	Stat map[string]int64 `json:"stat"`
+}
+
+// MemoryEvents counts limit events. v1 only reports OOMKill.
+type MemoryEvents struct {
+	High    int64 `json:"high"`
+	Max     int64 `json:"max"`
+	OOM     int64 `json:"oom"`
+	OOMKill int64 `json:"oomKill"`
+}
+
+// Memory reads the memory controller.
+func (r *Reader) Memory() (*Memory, error) {
+	switch r.Version() {
+	case 2:
+		return r.memoryV2()
+	case 1:
+		return r.memoryV1()
+	}
+	return nil, ErrNotFound
+}
+
+func (r *Reader) memoryV2() (*Memory, error) {
+	usage, err := readInt(r.path("memory.current"))
+	if err != nil {
+		return nil, err
+	}
+	m := &Memory{UsageBytes: usage, LimitBytes: -1}
+	if n, err := readInt(r.path("memory.max")); err == nil {
+		m.LimitBytes = n
+	}
+	if ev, err := readKeyed(r.path("memory.events")); err == nil {
+		m.Events = MemoryEvents{High: ev["high"], Max: ev["max"], OOM: ev["oom"], OOMKill: ev["oom_kill"]}
+	}
+	m.Stat, _ = readKeyed(r.path("memory.stat"))
+	m.WorkingSetBytes = workingSet(usage, m.Stat["inactive_file"])
+	return m, nil
+}
+
+func (r *Reader) memoryV1() (*Memory, error) {
+	usage, err := readInt(r.path("memory", "memory.usage_in_bytes"))
+	if err != nil {
+		return nil, err
+	}
+	m := &Memory{UsageBytes: usage, LimitBytes: -1}
+	if n, err := readInt(r.path("memory", "memory.limit_in_bytes")); err == nil {
+		m.LimitBytes = n
+	}
+	if oc, err := readKeyed(r.path("memory", "memory.oom_control")); err == nil {
+		m.Events.OOMKill = oc["oom_kill"]
+	}
+	m.Stat, _ = readKeyed(r.path("memory", "memory.stat"))
+	m.WorkingSetBytes = workingSet(usage, m.Stat["total_inactive_file"])
+	return m, nil
+}
+
+func workingSet(usage, inactiveFile int64) int64 {
+	return max(usage-inactiveFile, 0)
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cgroup
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+// Pids is the cgroup's process count and limit.
+type Pids struct {
+	Current int64 `json:"current"`
+	// Max is -1 if unlimited.
+	Max int64 `json:"max"`
+}
+
+// Pids reads the pids controller.
+func (r *Reader) Pids() (*Pids, error) {
+	dir := r.Root
+	switch r.Version() {
+	case 1:
+		dir = r.path("pids")
+	case 0:
+		return nil, ErrNotFound
+	}
+	cur, err := readInt(filepath.Join(dir, "pids.current"))
+	if err != nil {
+		return nil, err
+	}
+	p := &Pids{Current: cur, Max: -1}
+	if n, err := readInt(filepath.Join(dir, "pids.max")); err == nil {
+		p.Max = n
+	}
+	return p, nil
+}
+
+// Stats is every controller kuard reports. Controllers that aren't enabled
+// for the cgroup are left nil.
+type Stats struct {
+	Version int     `json:"version"`
+	Memory  *Memory `json:"memory,omitempty"`
+	CPU     *CPU    `json:"cpu,omitempty"`
+	Pids    *Pids   `json:"pids,omitempty"`
+}
+
+// Stats reads all controllers. It fails only if none can be read.
+func (r *Reader) Stats() (*Stats, error) {
+	s := &Stats{Version: r.Version()}
+	if s.Version == 0 {
+		return nil, ErrNotFound
+	}
+	var errs []error
+	var err error
+	if s.Memory, err = r.Memory(); err != nil {
+		errs = append(errs, err)
+	}
+	if s.CPU, err = r.CPU(); err != nil {
+		errs = append(errs, err)
+	}
+	if s.Pids, err = r.Pids(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) == 3 {
+		return nil, errors.Join(errs...)
+	}
+	return s, nil
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/apiutils"
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+// ChaosStatus is returned from a GET to this API endpoint.
+type ChaosStatus struct {
+	Pending        []Trigger `json:"pending"`
+	Deadlocked     bool      `json:"deadlocked"`
+	ProbesDeadlock bool      `json:"probesDeadlocked"`
+	TerminationLog string    `json:"terminationLog"`
+}
+
+func (c *Chaos) AddRoutes(r route.Router, base string) {
+	r.GET(base+"/api", route.Describe(http.HandlerFunc(c.APIGet), route.Meta{
+		Summary:  "Pending crash and hang triggers",
+		Response: ChaosStatus{},
+	}))
+	schedule := []string{"delay", "at"}
+	r.POST(base+"/api/panic", route.Describe(c.trigger(ActionPanic), route.Meta{
+		Summary:  "Crash with a Go panic",
+		Query:    schedule,
+		Response: Trigger{},
+	}))
+	r.POST(base+"/api/exit", route.Describe(c.trigger(ActionExit), route.Meta{
+		Summary:  "Exit with the given code",
+		Query:    append([]string{"code"}, schedule...),
+		Response: Trigger{},
+	}))
+	r.POST(base+"/api/kill", route.Describe(c.trigger(ActionKill), route.Meta{
+		Summary:  "Send SIGKILL to the kuard process",
+		Query:    schedule,
+		Response: Trigger{},
+	}))
+	r.POST(base+"/api/deadlock", route.Describe(c.trigger(ActionDeadlock), route.Meta{
+		Summary:  "Hang every handler, and the probes too if probes=true",
+		Query:    append([]string{"probes"}, schedule...),
+		Response: Trigger{},
+	}))
+	r.DELETE(base+"/api/:id", route.Describe(http.HandlerFunc(c.APICancel), route.Meta{
+		Summary: "Cancel a pending trigger",
+	}))
+}
+
+func (c *Chaos) APIGet(w http.ResponseWriter, _ *http.Request) {
+	app, probes := c.Deadlocked()
+	c.mu.Lock()
+	path := c.c.TerminationLog
+	c.mu.Unlock()
+	apiutils.ServeJSON(w, ChaosStatus{
+		Pending:        c.Pending(),
+		Deadlocked:     app,
+		ProbesDeadlock: probes,
+		TerminationLog: path,
+	})
+}
+
+// trigger schedules action after the delay query parameter, a duration, or
+// at the at parameter, an RFC 3339 time. Without either it fires right after
+// the response is sent.
+func (c *Chaos) trigger(action string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		t := Trigger{Action: action, At: time.Now()}
+		if q.Has("delay") && q.Has("at") {
+			http.Error(w, "give delay or at, not both", http.StatusBadRequest)
+			return
+		}
+		if s := q.Get("delay"); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil || d < 0 {
+				http.Error(w, "bad delay param", http.StatusBadRequest)
+				return
+			}
+			t.At = t.At.Add(d)
+		}
+		if s := q.Get("at"); s != "" {
+			at, err := time.Parse(time.RFC3339, s)
+			if err != nil {
+				http.Error(w, "bad at param", http.StatusBadRequest)
+				return
+			}
+			t.At = at
+		}
+		if s := q.Get("code"); s != "" {
+			code, err := strconv.Atoi(s)
+			if err != nil || code < 0 || code > 255 {
+				http.Error(w, "bad code param", http.StatusBadRequest)
+				return
+			}
+			t.Code = code
+		}
+		if s := q.Get("probes"); s != "" {
+			probes, err := strconv.ParseBool(s)
+			if err != nil {
+				http.Error(w, "bad probes param", http.StatusBadRequest)
+				return
+			}
+			t.Probes = probes
+		}
+		// Give an immediate action time to answer first.
+		if !t.At.After(time.Now()) {
+			t.At = time.Now().Add(100 * time.Millisecond)
+		}
+		t, err := c.Schedule(t)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		apiutils.ServeJSON(w, t)
+	})
+}
+
+func (c *Chaos) APICancel(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(route.Param(r, "id"))
+	if err != nil {
+		http.Error(w, "bad id", http.StatusBadRequest)
+		return
+	}
+	if !c.Cancel(id) {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
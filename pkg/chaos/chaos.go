@@ -0,0 +1,241 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package chaos crashes or hangs kuard on request, to demo restartPolicy,
+// CrashLoopBackOff and liveness probe recovery.
+package chaos
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+// Actions.
+const (
+	ActionPanic    = "panic"
+	ActionExit     = "exit"
+	ActionKill     = "kill"
+	ActionDeadlock = "deadlock"
+)
+
+// Deadlock scopes, from least to most hung.
+const (
+	hungNone int32 = iota
+	hungApp        // everything but the liveness and readiness probes
+	hungAll
+)
+
+// probeSubsystems keep answering in an app-only deadlock.
+var probeSubsystems = map[string]bool{"liveness": true, "readiness": true}
+
+// Trigger is a requested action, pending until At.
+type Trigger struct {
+	ID     int       `json:"id"`
+	Action string    `json:"action"`
+	Code   int       `json:"code,omitempty"`   // exit code
+	Probes bool      `json:"probes,omitempty"` // deadlock the probes too
+	At     time.Time `json:"at"`
+
+	timer *time.Timer
+}
+
+type Chaos struct {
+	mu      sync.Mutex
+	c       Config
+	pending map[int]*Trigger
+	nextID  int
+
+	// hung is the deadlock: it is locked once and never unlocked, and gated
+	// handlers block acquiring it.
+	hung  sync.Mutex
+	scope atomic.Int32
+
+	// Overridden in tests.
+	exit  func(code int)
+	kill  func()
+	crash func(msg string)
+}
+
+func New() *Chaos {
+	return &Chaos{
+		c:       Config{TerminationLog: DefaultTerminationLog},
+		pending: map[int]*Trigger{},
+		exit:    os.Exit,
+		kill: func() {
+			syscall.Kill(os.Getpid(), syscall.SIGKILL)
+		},
+		crash: func(msg string) {
+			// Panicking in a goroutine of our own, as net/http would recover
+			// a panic in the handler.
+			go panic(msg)
+		},
+	}
+}
+
+// Schedule arms t to fire at t.At, or now if that has passed.
+func (c *Chaos) Schedule(t Trigger) (Trigger, error) {
+	switch t.Action {
+	case ActionPanic, ActionExit, ActionKill, ActionDeadlock:
+	default:
+		return t, fmt.Errorf("unknown action %q", t.Action)
+	}
+	if t.At.IsZero() {
+		t.At = time.Now()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nextID++
+	t.ID = c.nextID
+	p := t
+	c.pending[t.ID] = &p
+	p.timer = time.AfterFunc(time.Until(t.At), func() { c.fire(t.ID) })
+	slog.Warn("chaos scheduled", "id", t.ID, "action", t.Action, "at", t.At)
+	return t, nil
+}
+
+// Cancel stops a pending trigger. It reports false if there is none with id.
+func (c *Chaos) Cancel(id int) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t, ok := c.pending[id]
+	if !ok || !t.timer.Stop() {
+		return false
+	}
+	delete(c.pending, id)
+	slog.Info("chaos cancelled", "id", id, "action", t.Action)
+	return true
+}
+
+// Pending returns the triggers that have not fired yet.
+func (c *Chaos) Pending() []Trigger {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := []Trigger{}
+	for _, t := range c.pending {
+		out = append(out, *t)
+	}
+	slices.SortFunc(out, func(a, b Trigger) int { return a.At.Compare(b.At) })
+	return out
+}
+
+func (c *Chaos) fire(id int) {
+	c.mu.Lock()
+	t, ok := c.pending[id]
+	delete(c.pending, id)
+	c.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	msg := describe(t)
+	slog.Error("chaos triggered", "id", t.ID, "action", t.Action, "message", msg)
+	c.record(msg)
+
+	switch t.Action {
+	case ActionPanic:
+		c.crash(msg)
+	case ActionExit:
+		c.exit(t.Code)
+	case ActionKill:
+		c.kill()
+	case ActionDeadlock:
+		c.deadlock(t.Probes)
+	}
+}
+
+func describe(t *Trigger) string {
+	switch t.Action {
+	case ActionExit:
+		return fmt.Sprintf("kuard chaos: exit with code %d", t.Code)
+	case ActionKill:
+		return "kuard chaos: SIGKILL sent to self"
+	case ActionDeadlock:
+		if t.Probes {
+			return "kuard chaos: deadlocked all handlers including probes"
+		}
+		return "kuard chaos: deadlocked all handlers except probes"
+	}
+	return "kuard chaos: panic"
+}
+
+// record writes msg as the termination message. The file is not created, so
+// nothing is left behind outside Kubernetes.
+func (c *Chaos) record(msg string) {
+	c.mu.Lock()
+	path := c.c.TerminationLog
+	c.mu.Unlock()
+	if path == "" {
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		slog.Debug("termination message not written", "path", path, "error", err)
+		return
+	}
+	defer f.Close()
+	fmt.Fprintf(f, "%s at %s\n", msg, time.Now().UTC().Format(time.RFC3339))
+	f.Sync()
+}
+
+func (c *Chaos) deadlock(probes bool) {
+	scope := hungApp
+	if probes {
+		scope = hungAll
+	}
+	for {
+		old := c.scope.Load()
+		if old >= scope {
+			return
+		}
+		if c.scope.CompareAndSwap(old, scope) {
+			break
+		}
+	}
+	c.hung.TryLock()
+}
+
+// Deadlocked reports whether handlers are hung, and whether the probes are.
+func (c *Chaos) Deadlocked() (app, probes bool) {
+	s := c.scope.Load()
+	return s >= hungApp, s >= hungAll
+}
+
+func (c *Chaos) gate(h http.Handler) http.Handler {
+	m, _ := route.MetaOf(h)
+	probe := probeSubsystems[m.Subsystem]
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s := c.scope.Load(); s == hungAll || (s == hungApp && !probe) {
+			c.hung.Lock()
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Router wraps r so handlers registered through it hang once a deadlock is
+// triggered. Registrations must already be tagged with their subsystem.
+func (c *Chaos) Router(r route.Router) route.Router {
+	return route.Wrap(r, func(_, _ string, h http.Handler) http.Handler { return c.gate(h) })
+}
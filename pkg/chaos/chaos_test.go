@@ -0,0 +1,148 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+func newTestChaos(t *testing.T) (*Chaos, string, chan string) {
+	t.Helper()
+	log := filepath.Join(t.TempDir(), "termination-log")
+	if err := os.WriteFile(log, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	fired := make(chan string, 4)
+	c := New()
+	c.SetConfig(Config{TerminationLog: log})
+	c.exit = func(code int) { fired <- "exit " + strconv.Itoa(code) }
+	c.kill = func() { fired <- "kill" }
+	c.crash = func(string) { fired <- "panic" }
+	return c, log, fired
+}
+
+func TestExitIsRecorded(t *testing.T) {
+	c, log, fired := newTestChaos(t)
+
+	w := httptest.NewRecorder()
+	c.trigger(ActionExit).ServeHTTP(w, httptest.NewRequest("POST", "/chaos/api/exit?code=3", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("exit: %d %s", w.Code, w.Body)
+	}
+	select {
+	case got := <-fired:
+		if got != "exit 3" {
+			t.Fatalf("fired %q, want exit 3", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("exit never fired")
+	}
+	msg, _ := os.ReadFile(log)
+	if !strings.Contains(string(msg), "exit with code 3") {
+		t.Fatalf("termination message %q", msg)
+	}
+}
+
+func TestScheduleAndCancel(t *testing.T) {
+	c, _, fired := newTestChaos(t)
+
+	for _, q := range []string{"delay=-1s", "delay=soon", "at=tomorrow", "code=300", "delay=1s&at=2030-01-01T00:00:00Z"} {
+		w := httptest.NewRecorder()
+		c.trigger(ActionExit).ServeHTTP(w, httptest.NewRequest("POST", "/chaos/api/exit?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: %d, want 400", q, w.Code)
+		}
+	}
+
+	kill, _ := c.Schedule(Trigger{Action: ActionKill, At: time.Now().Add(time.Hour)})
+	at := time.Now().Add(time.Minute).Format(time.RFC3339)
+	w := httptest.NewRecorder()
+	c.trigger(ActionPanic).ServeHTTP(w, httptest.NewRequest("POST", "/chaos/api/panic?at="+at, nil))
+	if p := c.Pending(); len(p) != 2 || p[0].Action != ActionPanic {
+		t.Fatalf("pending %+v", p)
+	}
+	if !c.Cancel(kill.ID) || c.Cancel(kill.ID) {
+		t.Fatalf("cancel should succeed once")
+	}
+	if p := c.Pending(); len(p) != 1 {
+		t.Fatalf("pending after cancel %+v", p)
+	}
+	select {
+	case got := <-fired:
+		t.Fatalf("%s fired early", got)
+	default:
+	}
+}
+
+func TestDeadlockSparesProbes(t *testing.T) {
+	c, _, _ := newTestChaos(t)
+	h := map[string]http.Handler{}
+	r := c.Router(recordRouter(h))
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	r.GET("/healthy", route.Describe(ok, route.Meta{Subsystem: "liveness"}))
+	r.GET("/pageinfo", route.Describe(ok, route.Meta{Subsystem: "app"}))
+
+	// returns reports whether a request to pattern finishes. Hung requests
+	// are left blocked for good.
+	returns := func(pattern string) bool {
+		done := make(chan struct{})
+		go func() {
+			h[pattern].ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", pattern, nil))
+			close(done)
+		}()
+		select {
+		case <-done:
+			return true
+		case <-time.After(100 * time.Millisecond):
+			return false
+		}
+	}
+
+	c.deadlock(false)
+	if !returns("/healthy") {
+		t.Fatalf("probe hung in app deadlock")
+	}
+	if returns("/pageinfo") {
+		t.Fatalf("expected /pageinfo to hang")
+	}
+
+	c.deadlock(true)
+	if app, probes := c.Deadlocked(); !app || !probes {
+		t.Fatalf("Deadlocked() = %v, %v", app, probes)
+	}
+	if returns("/healthy") {
+		t.Fatalf("expected probe to hang")
+	}
+}
+
+// recordRouter keeps the handlers registered through it by pattern.
+type recordRouter map[string]http.Handler
+
+func (m recordRouter) GET(p string, h http.Handler)    { m[p] = h }
+func (m recordRouter) POST(p string, h http.Handler)   { m[p] = h }
+func (m recordRouter) PUT(p string, h http.Handler)    { m[p] = h }
+func (m recordRouter) PATCH(p string, h http.Handler)  { m[p] = h }
+func (m recordRouter) DELETE(p string, h http.Handler) { m[p] = h }
@@ -0,0 +1,52 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package chaos
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Config controls where triggers are recorded.
+type Config struct {
+	// TerminationLog is the container's terminationMessagePath. It is only
+	// written if it exists, as it does when Kubernetes mounts it.
+	TerminationLog string `json:"terminationLog" mapstructure:"termination-log"`
+}
+
+// DefaultTerminationLog is the Kubernetes default terminationMessagePath.
+const DefaultTerminationLog = "/dev/termination-log"
+
+func (c *Chaos) SetConfig(cfg Config) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.c = cfg
+}
+
+func BindConfig(v *viper.Viper, fs *pflag.FlagSet) {
+	fs.String("chaos-termination-log", DefaultTerminationLog, "File that chaos triggers are recorded in, shown by kubectl as the termination message")
+
+	fs.VisitAll(func(f *pflag.Flag) {
+		name := strings.TrimPrefix(f.Name, "chaos-")
+		if name != f.Name {
+			v.BindPFlag("chaos."+name, f)
+		}
+	})
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cpu burns a target amount of CPU, to demo the HorizontalPodAutoscaler
+// and CPU limit throttling.
+package cpu
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/apiutils"
+	"github.com/kubernetes-up-and-running/kuard/pkg/cgroup"
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+var targetMillicores = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: "kuard",
+	Subsystem: "cpu",
+	Name:      "burn_target_millicores",
+	Help:      "Millicores the CPU burn is currently aiming for.",
+})
+
+func init() {
+	prometheus.MustRegister(targetMillicores)
+}
+
+type CPUAPI struct {
+	cg *cgroup.Reader
+
+	mu     sync.Mutex
+	burn   *Burn
+	cancel context.CancelFunc
+}
+
+// CPUStatus is returned from a GET to this API endpoint.
+type CPUStatus struct {
+	Burn              *Burn       `json:"burn,omitempty"`
+	TargetMillicores  float64     `json:"targetMillicores"`
+	ProcessCPUSeconds float64     `json:"processCpuSeconds"`
+	Cgroup            *cgroup.CPU `json:"cgroup,omitempty"`
+	CgroupError       string      `json:"cgroupError,omitempty"`
+}
+
+func New(cg *cgroup.Reader) *CPUAPI {
+	return &CPUAPI{cg: cg}
+}
+
+func (c *CPUAPI) AddRoutes(r route.Router, base string) {
+	r.GET(base+"/api", route.Describe(http.HandlerFunc(c.APIGet), route.Meta{
+		Summary:  "CPU burn progress and cgroup CPU throttling",
+		Response: CPUStatus{},
+	}))
+	r.POST(base+"/api/burn", route.Describe(http.HandlerFunc(c.APIBurn), route.Meta{
+		Summary:  "Burn millicores of CPU, replacing any burn in progress",
+		Query:    []string{"millicores", "duration", "profile", "from", "ramp", "steps"},
+		Response: Burn{},
+	}))
+	r.DELETE(base+"/api/burn", route.Describe(http.HandlerFunc(c.APIStop), route.Meta{
+		Summary: "Stop the CPU burn",
+	}))
+}
+
+func (c *CPUAPI) APIGet(w http.ResponseWriter, _ *http.Request) {
+	resp := &CPUStatus{ProcessCPUSeconds: processCPUSeconds()}
+
+	c.mu.Lock()
+	if c.burn != nil {
+		b := *c.burn
+		resp.Burn = &b
+		resp.TargetMillicores = b.level(time.Since(b.Started))
+	}
+	c.mu.Unlock()
+
+	cg, err := c.cg.CPU()
+	if err != nil {
+		resp.CgroupError = err.Error()
+	}
+	resp.Cgroup = cg
+
+	apiutils.ServeJSON(w, resp)
+}
+
+// APIBurn starts a burn. millicores is required; duration, ramp and the
+// other parameters are optional.
+func (c *CPUAPI) APIBurn(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	b := &Burn{Profile: q.Get("profile")}
+	switch b.Profile {
+	case "":
+		b.Profile = ProfileConstant
+	case ProfileStep:
+		b.Steps = 5
+	}
+
+	var err error
+	if b.Millicores, err = strconv.ParseInt(q.Get("millicores"), 10, 64); err != nil {
+		http.Error(w, "bad millicores param", http.StatusBadRequest)
+		return
+	}
+	if s := q.Get("from"); s != "" {
+		if b.From, err = strconv.ParseInt(s, 10, 64); err != nil {
+			http.Error(w, "bad from param", http.StatusBadRequest)
+			return
+		}
+	}
+	for name, dst := range map[string]*apiutils.Duration{"duration": &b.Duration, "ramp": &b.Ramp} {
+		if s := q.Get(name); s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				http.Error(w, "bad "+name+" param", http.StatusBadRequest)
+				return
+			}
+			*dst = apiutils.Duration(d)
+		}
+	}
+	if s := q.Get("steps"); s != "" {
+		if b.Steps, err = strconv.Atoi(s); err != nil {
+			http.Error(w, "bad steps param", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := b.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c.Start(b)
+	apiutils.ServeJSON(w, b)
+}
+
+func (c *CPUAPI) APIStop(w http.ResponseWriter, _ *http.Request) {
+	c.Stop()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Start replaces any burn in progress with b.
+func (c *CPUAPI) Start(b *Burn) {
+	b.Workers = b.workers()
+	b.Started = time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopLocked()
+
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if b.Duration > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(b.Duration))
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	c.burn, c.cancel = b, cancel
+	b.run(ctx)
+	go c.report(ctx, b)
+	slog.Info("cpu burn started", "millicores", b.Millicores, "profile", b.Profile, "duration", time.Duration(b.Duration), "workers", b.Workers)
+}
+
+// report keeps the target gauge current and clears the burn once it ends.
+// The gauge is only written under c.mu, so a tick can't overwrite the zero
+// left by stopLocked.
+func (c *CPUAPI) report(ctx context.Context, b *Burn) {
+	t := time.NewTicker(time.Second)
+	defer t.Stop()
+	for {
+		c.mu.Lock()
+		if c.burn != b {
+			c.mu.Unlock()
+			return
+		}
+		targetMillicores.Set(b.level(time.Since(b.Started)))
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			c.mu.Lock()
+			if c.burn == b {
+				c.burn, c.cancel = nil, nil
+				targetMillicores.Set(0)
+				slog.Info("cpu burn finished")
+			}
+			c.mu.Unlock()
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (c *CPUAPI) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopLocked()
+}
+
+// stopLocked cancels any burn and zeroes the gauge last. c.mu must be held.
+func (c *CPUAPI) stopLocked() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.burn, c.cancel = nil, nil
+	targetMillicores.Set(0)
+}
+
+func processCPUSeconds() float64 {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return 0
+	}
+	return time.Duration(ru.Utime.Nano() + ru.Stime.Nano()).Seconds()
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/apiutils"
+	"github.com/kubernetes-up-and-running/kuard/pkg/cgroup"
+)
+
+func TestLevel(t *testing.T) {
+	for _, tc := range []struct {
+		b       Burn
+		elapsed time.Duration
+		want    float64
+	}{
+		{Burn{Profile: ProfileConstant, Millicores: 500}, time.Hour, 500},
+		{Burn{Profile: ProfileLinear, From: 100, Millicores: 1100, Ramp: apiutils.Duration(10 * time.Second)}, 5 * time.Second, 600},
+		{Burn{Profile: ProfileLinear, From: 100, Millicores: 1100, Ramp: apiutils.Duration(10 * time.Second)}, time.Minute, 1100},
+		{Burn{Profile: ProfileStep, Millicores: 1000, Steps: 5, Ramp: apiutils.Duration(10 * time.Second)}, time.Second, 0},
+		{Burn{Profile: ProfileStep, Millicores: 1000, Steps: 5, Ramp: apiutils.Duration(10 * time.Second)}, 5 * time.Second, 500},
+		{Burn{Profile: ProfileStep, Millicores: 1000, Steps: 5, Ramp: apiutils.Duration(10 * time.Second)}, time.Minute, 1000},
+		{Burn{Profile: ProfileSine, From: 200, Millicores: 1000, Ramp: apiutils.Duration(10 * time.Second)}, 0, 200},
+		{Burn{Profile: ProfileSine, From: 200, Millicores: 1000, Ramp: apiutils.Duration(10 * time.Second)}, 5 * time.Second, 1000},
+	} {
+		if got := tc.b.level(tc.elapsed); got < tc.want-0.001 || got > tc.want+0.001 {
+			t.Fatalf("%s at %v: level %v, want %v", tc.b.Profile, tc.elapsed, got, tc.want)
+		}
+	}
+	if w := (&Burn{Millicores: 2500}).workers(); w != 3 {
+		t.Fatalf("workers %d, want 3", w)
+	}
+}
+
+func TestBurnAPI(t *testing.T) {
+	c := New(cgroup.New(t.TempDir()))
+
+	tooMany := strconv.Itoa(runtime.NumCPU()*1000 + 1)
+	for _, q := range []string{"", "millicores=x", "millicores=-1", "millicores=500&profile=linear", "millicores=500&profile=zigzag&ramp=1s", "millicores=500&duration=soon",
+		"millicores=" + tooMany, "millicores=500&from=" + tooMany + "&profile=linear&ramp=1s"} {
+		w := httptest.NewRecorder()
+		c.APIBurn(w, httptest.NewRequest("POST", "/cpu/api/burn?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%q: %d, want 400", q, w.Code)
+		}
+	}
+
+	before := processCPUSeconds()
+	w := httptest.NewRecorder()
+	c.APIBurn(w, httptest.NewRequest("POST", "/cpu/api/burn?millicores=800&duration=400ms", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("burn: %d %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), `"duration":"400ms"`) {
+		t.Fatalf("duration not reported as a string: %s", w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	c.APIGet(w, httptest.NewRequest("GET", "/cpu/api", nil))
+	var st CPUStatus
+	json.NewDecoder(w.Body).Decode(&st)
+	if st.Burn == nil || st.TargetMillicores != 800 || st.CgroupError == "" {
+		t.Fatalf("status during burn %+v", st)
+	}
+
+	time.Sleep(600 * time.Millisecond)
+	if used := processCPUSeconds() - before; used < 0.1 {
+		t.Fatalf("burned %.3fs of CPU, want about 0.32s", used)
+	}
+	c.mu.Lock()
+	done := c.burn == nil
+	c.mu.Unlock()
+	if !done {
+		t.Fatalf("burn still running after its duration")
+	}
+}
+
+func TestStopZeroesTarget(t *testing.T) {
+	c := New(cgroup.New(t.TempDir()))
+	for range 20 {
+		c.Start(&Burn{Profile: ProfileConstant, Millicores: 100})
+		c.Stop()
+	}
+	time.Sleep(50 * time.Millisecond)
+	if v := testutil.ToFloat64(targetMillicores); v != 0 {
+		t.Fatalf("target %v after stop, want 0", v)
+	}
+}
@@ -0,0 +1,133 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cpu
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"time"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/apiutils"
+)
+
+// Ramp profiles.
+const (
+	ProfileConstant = "constant"
+	ProfileStep     = "step"
+	ProfileLinear   = "linear"
+	ProfileSine     = "sine"
+)
+
+// dutyPeriod is how often each worker alternates between spinning and
+// sleeping. It is shorter than the 100ms CFS period so a burn under a CPU
+// limit is throttled the way a real busy service would be.
+const dutyPeriod = 50 * time.Millisecond
+
+// Burn is a CPU burn in progress.
+type Burn struct {
+	// Millicores is the target. With a ramp profile the burn moves from From
+	// to Millicores over Ramp.
+	Millicores int64             `json:"millicores"`
+	From       int64             `json:"from"`
+	Profile    string            `json:"profile"`
+	Ramp       apiutils.Duration `json:"ramp"`
+	Steps      int               `json:"steps,omitempty"`
+	Duration   apiutils.Duration `json:"duration"` // 0 runs until cancelled
+	Workers    int               `json:"workers"`
+	Started    time.Time         `json:"started"`
+}
+
+func (b *Burn) validate() error {
+	if b.Millicores < 0 || b.From < 0 {
+		return fmt.Errorf("millicores must not be negative")
+	}
+	// More workers than CPUs can't burn any harder, only spawn goroutines.
+	if limit := int64(runtime.NumCPU()) * 1000; b.Millicores > limit || b.From > limit {
+		return fmt.Errorf("millicores must not exceed %d, 1000 per CPU", limit)
+	}
+	if b.Duration < 0 || b.Ramp < 0 {
+		return fmt.Errorf("duration and ramp must not be negative")
+	}
+	switch b.Profile {
+	case ProfileConstant:
+	case ProfileStep:
+		if b.Steps < 2 {
+			return fmt.Errorf("step profile needs at least 2 steps")
+		}
+		fallthrough
+	case ProfileLinear, ProfileSine:
+		if b.Ramp == 0 {
+			return fmt.Errorf("%s profile needs a ramp", b.Profile)
+		}
+	default:
+		return fmt.Errorf("unknown profile %q", b.Profile)
+	}
+	return nil
+}
+
+// level returns the target millicores elapsed into the burn. Step and linear
+// ramps hold the target once the ramp is over; sine repeats every Ramp.
+func (b *Burn) level(elapsed time.Duration) float64 {
+	lo, hi := float64(b.From), float64(b.Millicores)
+	frac := 1.0
+	if b.Ramp > 0 {
+		frac = float64(elapsed) / float64(b.Ramp)
+	}
+	switch b.Profile {
+	case ProfileLinear:
+		return lo + (hi-lo)*min(frac, 1)
+	case ProfileStep:
+		steps := float64(b.Steps)
+		step := min(math.Floor(frac*steps), steps-1)
+		return lo + (hi-lo)*step/(steps-1)
+	case ProfileSine:
+		return lo + (hi-lo)*(1-math.Cos(2*math.Pi*frac))/2
+	}
+	return hi
+}
+
+// workers is how many goroutines are needed to reach the highest level, one
+// per whole or part core.
+func (b *Burn) workers() int {
+	peak := max(b.From, b.Millicores)
+	return max(1, int((peak+999)/1000))
+}
+
+// run spins b.Workers goroutines until ctx is done.
+func (b *Burn) run(ctx context.Context) {
+	for range b.Workers {
+		go b.work(ctx)
+	}
+}
+
+func (b *Burn) work(ctx context.Context) {
+	for {
+		start := time.Now()
+		duty := b.level(start.Sub(b.Started)) / 1000 / float64(b.Workers)
+		busy := time.Duration(float64(dutyPeriod) * min(max(duty, 0), 1))
+		for time.Since(start) < busy {
+			// Spin.
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(dutyPeriod - busy):
+		}
+	}
+}
@@ -20,6 +20,7 @@ package debugprobe
 type ProbeStatus struct {
 	ProbePath string               `json:"probePath"`
 	FailNext  int                  `json:"failNext"`
+	Draining  bool                 `json:"draining"`
 	History   []ProbeStatusHistory `json:"history"`
 }
 
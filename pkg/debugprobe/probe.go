@@ -36,8 +36,9 @@ type Probe struct {
 
 	lastID int
 
-	c       ProbeConfig
-	history []*ProbeHistory
+	c        ProbeConfig
+	draining bool
+	history  []*ProbeHistory
 }
 
 type ProbeHistory struct {
@@ -52,8 +53,15 @@ func New() *Probe {
 
 func (p *Probe) AddRoutes(r route.Router, base string) {
 	r.GET(base, http.HandlerFunc(p.Handle))
-	r.GET(base+"/api", http.HandlerFunc(p.APIGet))
-	r.PUT(base+"/api", http.HandlerFunc(p.APIPut))
+	r.GET(base+"/api", route.Describe(http.HandlerFunc(p.APIGet), route.Meta{
+		Summary:  "Probe config and recent history",
+		Response: ProbeStatus{},
+	}))
+	r.PUT(base+"/api", route.Describe(http.HandlerFunc(p.APIPut), route.Meta{
+		Summary:  "Configure probe failures",
+		Request:  ProbeConfig{},
+		Response: ProbeStatus{},
+	}))
 
 	if p.basePath != "" {
 		p.basePath = base
@@ -71,6 +79,7 @@ func (p *Probe) lockedGet(w http.ResponseWriter, r *http.Request) {
 	s := &ProbeStatus{
 		ProbePath: p.basePath,
 		FailNext:  p.c.FailNext,
+		Draining:  p.draining,
 	}
 	l := len(p.history)
 	s.History = make([]ProbeStatusHistory, l)
@@ -105,7 +114,10 @@ func (p *Probe) Handle(w http.ResponseWriter, r *http.Request) {
 
 	status := http.StatusOK
 	message := "ok"
-	if p.c.FailNext > 0 {
+	if p.draining {
+		status = http.StatusServiceUnavailable
+		message = "fail, draining"
+	} else if p.c.FailNext > 0 {
 		status = http.StatusInternalServerError
 		p.c.FailNext--
 		message = fmt.Sprintf("fail, %d left", p.c.FailNext)
@@ -120,6 +132,15 @@ func (p *Probe) Handle(w http.ResponseWriter, r *http.Request) {
 	p.recordRequest(r, status)
 }
 
+// SetDraining makes the probe fail while the server drains before shutdown,
+// regardless of the configured FailNext.
+func (p *Probe) SetDraining(d bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.draining = d
+}
+
 func (p *Probe) recordRequest(_ *http.Request, code int) {
 	p.lastID++
 	entry := &ProbeHistory{
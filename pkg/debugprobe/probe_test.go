@@ -37,3 +37,19 @@ func TestProbeFailNext(t *testing.T) {
 		t.Fatalf("expected success after decrement, got %d", r3.Code)
 	}
 }
+
+func TestProbeDraining(t *testing.T) {
+	p := New()
+	p.SetDraining(true)
+	w := httptest.NewRecorder()
+	p.Handle(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while draining, got %d", w.Code)
+	}
+	p.SetDraining(false)
+	w = httptest.NewRecorder()
+	p.Handle(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	if w.Code != 200 {
+		t.Fatalf("expected success after drain cleared, got %d", w.Code)
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dirwatch follows updates to a directory mounted from a ConfigMap,
+// Secret, downward API or projected volume.
+package dirwatch
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Debounce coalesces the burst of events produced by a single update.
+const Debounce = 200 * time.Millisecond
+
+// Watch calls reload after each burst of changes in dir, until ctx is
+// cancelled. The directory itself is watched, not the files: the kubelet
+// updates these volumes by swapping the ..data symlink, which changes every
+// file at once without writing to any of them.
+func Watch(ctx context.Context, dir string, reload func()) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if err := w.Add(dir); err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(Debounce)
+	timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+			timer.Reset(Debounce)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			slog.Warn("directory watch error", "dir", dir, "error", err)
+		case <-timer.C:
+			reload()
+		}
+	}
+}
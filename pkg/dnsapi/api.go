@@ -45,7 +45,12 @@ func New() *DNSAPI {
 }
 
 func (e *DNSAPI) AddRoutes(r route.Router, base string) {
-	r.POST(base+"/api", http.HandlerFunc(e.APIGet))
+	r.POST(base+"/api", route.Describe(http.HandlerFunc(e.APIGet), route.Meta{
+		Summary:  "Run a DNS query using the pod resolver",
+		Request:  DNSRequest{},
+		Response: DNSResponse{},
+		ReadOnly: true,
+	}))
 }
 
 func (e *DNSAPI) APIGet(w http.ResponseWriter, r *http.Request) {
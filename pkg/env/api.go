@@ -19,9 +19,12 @@ package env
 import (
 	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/kubernetes-up-and-running/kuard/pkg/apiutils"
+	"github.com/kubernetes-up-and-running/kuard/pkg/auth"
 	"github.com/kubernetes-up-and-running/kuard/pkg/route"
 )
 
@@ -29,30 +32,73 @@ import (
 type EnvStatus struct {
 	CommandLine []string          `json:"commandLine"`
 	Env         map[string]string `json:"env"`
+
+	// ConfigVars maps each environment variable that supplied a kuard
+	// setting, e.g. KUARD_READINESS_FAIL_NEXT, to the setting's key.
+	ConfigVars map[string]string `json:"configVars,omitempty"`
+
+	// Masked lists the variables whose values were masked, and
+	// MaskedCommandLine says whether any flag values were.
+	Masked            []string `json:"masked,omitempty"`
+	MaskedCommandLine bool     `json:"maskedCommandLine,omitempty"`
 }
 
 type Env struct {
+	mu         sync.Mutex
+	c          Config
+	configVars map[string]string
 }
 
 func New() *Env {
-	return &Env{}
+	return &Env{c: Config{MaskPatterns: DefaultMaskPatterns}}
 }
 
 func (e *Env) AddRoutes(r route.Router, base string) {
-	r.GET(base+"/api", http.HandlerFunc(e.APIGet))
+	r.GET(base+"/api", route.Describe(http.HandlerFunc(e.APIGet), route.Meta{
+		Summary:  "Command line and environment of the process, with secrets masked",
+		Query:    []string{"reveal"},
+		Response: EnvStatus{},
+	}))
+}
+
+// SetConfigVars records which environment variables supplied settings.
+func (e *Env) SetConfigVars(vars map[string]string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.configVars = vars
 }
 
+// APIGet returns the command line and environment. Values that look secret
+// are masked unless ?reveal=true is given on an authenticated request.
 func (e *Env) APIGet(w http.ResponseWriter, r *http.Request) {
+	reveal := r.URL.Query().Get("reveal") == "true"
+	if _, authenticated := auth.UserFromContext(r.Context()); reveal && !authenticated {
+		http.Error(w, "reveal requires authentication to be enabled for the env subsystem", http.StatusForbidden)
+		return
+	}
+
 	s := EnvStatus{}
+	e.mu.Lock()
+	s.ConfigVars = e.configVars
+	m := newMasker(e.c.MaskPatterns)
+	e.mu.Unlock()
 
 	s.CommandLine = os.Args
+	if !reveal {
+		s.CommandLine, s.MaskedCommandLine = m.maskArgs(os.Args)
+	}
 
 	s.Env = map[string]string{}
 	for _, e := range os.Environ() {
 		splits := strings.SplitN(e, "=", 2)
 		k, v := splits[0], splits[1]
+		if !reveal && m.secret(k, v) {
+			v = mask(v)
+			s.Masked = append(s.Masked, k)
+		}
 		s.Env[k] = v
 	}
+	sort.Strings(s.Masked)
 
 	apiutils.ServeJSON(w, s)
 }
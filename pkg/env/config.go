@@ -0,0 +1,52 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package env
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Config controls which values the env API masks.
+type Config struct {
+	// MaskPatterns are shell globs matched, case-insensitively, against
+	// variable and flag names, e.g. *TOKEN* or *_KEY.
+	MaskPatterns []string `json:"maskPatterns" mapstructure:"mask-patterns"`
+}
+
+// DefaultMaskPatterns catch the usual names for credentials.
+var DefaultMaskPatterns = []string{"*TOKEN*", "*PASSWORD*", "*PASSWD*", "*SECRET*", "*CREDENTIAL*", "*_KEY", "*APIKEY*"}
+
+func (e *Env) SetConfig(c Config) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.c = c
+}
+
+func BindConfig(v *viper.Viper, fs *pflag.FlagSet) {
+	fs.StringSlice("env-mask-patterns", DefaultMaskPatterns, "Name globs of environment variables and flags whose values /env/api masks")
+
+	fs.VisitAll(func(f *pflag.Flag) {
+		name := strings.TrimPrefix(f.Name, "env-")
+		if name != f.Name {
+			v.BindPFlag("env."+name, f)
+		}
+	})
+}
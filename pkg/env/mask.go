@@ -0,0 +1,94 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package env
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/redact"
+)
+
+// masker decides which values to hide.
+type masker struct {
+	patterns []string
+}
+
+func newMasker(patterns []string) masker {
+	m := masker{}
+	for _, p := range patterns {
+		m.patterns = append(m.patterns, strings.ToUpper(p))
+	}
+	return m
+}
+
+// secretName reports whether name matches a mask pattern.
+func (m masker) secretName(name string) bool {
+	name = strings.ToUpper(name)
+	for _, p := range m.patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// secret reports whether the value of name should be masked.
+func (m masker) secret(name, value string) bool {
+	if value == "" {
+		return false
+	}
+	return m.secretName(name) || redact.Value("", value) != value
+}
+
+// mask hides s but keeps its length and a short hash, so the same secret can
+// be recognized across pods without showing it.
+func mask(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return fmt.Sprintf("masked(len=%d,sha256=%s)", len(s), hex.EncodeToString(sum[:4]))
+}
+
+// maskArgs masks the values of flags whose names match a pattern, given as
+// --name=value or --name value. Flag names are compared as environment
+// variable names, e.g. --auth-token as AUTH_TOKEN.
+func (m masker) maskArgs(args []string) (out []string, masked bool) {
+	out = make([]string, len(args))
+	copy(out, args)
+	for i := 0; i < len(out); i++ {
+		arg := out[i]
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !m.secretName(strings.ReplaceAll(name, "-", "_")) {
+			continue
+		}
+		switch {
+		case hasValue:
+			out[i] = arg[:len(arg)-len(value)] + mask(value)
+			masked = true
+		case i+1 < len(out) && !strings.HasPrefix(out[i+1], "-"):
+			out[i+1] = mask(out[i+1])
+			masked = true
+			i++
+		}
+	}
+	return out, masked
+}
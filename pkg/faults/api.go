@@ -0,0 +1,74 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package faults
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/apiutils"
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+func (in *Injector) APIGet(w http.ResponseWriter, r *http.Request) {
+	apiutils.ServeJSON(w, in.Rules())
+}
+
+func (in *Injector) APIPost(w http.ResponseWriter, r *http.Request) {
+	var rule Rule
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rule, err := in.add(rule)
+	if err != nil {
+		code := http.StatusBadRequest
+		if errors.Is(err, ErrDuplicateID) {
+			code = http.StatusConflict
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	apiutils.ServeJSON(w, rule)
+}
+
+func (in *Injector) APIPut(w http.ResponseWriter, r *http.Request) {
+	var rules []Rule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := in.SetRules(rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	in.APIGet(w, r)
+}
+
+func (in *Injector) APIDeleteAll(w http.ResponseWriter, r *http.Request) {
+	in.SetRules(nil)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (in *Injector) APIDelete(w http.ResponseWriter, r *http.Request) {
+	if !in.remove(route.Param(r, "id")) {
+		http.NotFound(w, r)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package faults injects latency, error statuses and dropped connections
+// into requests that match configurable rules, to demo retries, timeouts and
+// outlier detection in front of kuard.
+package faults
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+var injections = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "kuard",
+	Subsystem: "faults",
+	Name:      "injections_total",
+	Help:      "Faults injected, by rule and action (latency, status or abort).",
+}, []string{"rule", "action"})
+
+func init() {
+	prometheus.MustRegister(injections)
+}
+
+// ErrDuplicateID is returned when a rule's ID is already in use.
+var ErrDuplicateID = errors.New("duplicate fault rule id")
+
+// Injector holds the rules and applies them to routes registered through
+// its Router.
+type Injector struct {
+	// VariantOf returns the variant serving a request, for rules that match
+	// on it.
+	VariantOf func(*http.Request) string
+
+	mu     sync.RWMutex
+	rules  []Rule
+	fixed  []Rule
+	nextID int
+}
+
+func New() *Injector {
+	return &Injector{VariantOf: func(*http.Request) string { return "" }}
+}
+
+// SetRules validates and replaces all rules.
+func (in *Injector) SetRules(rules []Rule) error {
+	rules = append([]Rule{}, rules...)
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for i := range rules {
+		in.assignID(&rules[i])
+	}
+	if err := ValidateRules(rules); err != nil {
+		return err
+	}
+	in.rules = rules
+	return nil
+}
+
+// SetFixedRules validates and replaces the rules derived from other settings,
+// such as a variant's delay and error rate. They are matched after the rules
+// from SetRules and can't be changed through the API.
+func (in *Injector) SetFixedRules(rules []Rule) error {
+	if err := ValidateRules(rules); err != nil {
+		return err
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.fixed = append([]Rule{}, rules...)
+	return nil
+}
+
+// ValidateRules checks each rule and that named rules have unique IDs.
+func ValidateRules(rules []Rule) error {
+	var errs []error
+	seen := map[string]bool{}
+	for i := range rules {
+		if id := rules[i].ID; id != "" {
+			if seen[id] {
+				errs = append(errs, fmt.Errorf("%w %q", ErrDuplicateID, id))
+			}
+			seen[id] = true
+		}
+		if err := rules[i].Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Rules returns a copy of the rules in match order.
+func (in *Injector) Rules() []Rule {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	return append([]Rule{}, in.rules...)
+}
+
+func (in *Injector) add(r Rule) (Rule, error) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	in.assignID(&r)
+	for _, have := range in.rules {
+		if have.ID == r.ID {
+			return r, fmt.Errorf("%w %q", ErrDuplicateID, r.ID)
+		}
+	}
+	if err := r.Validate(); err != nil {
+		return r, err
+	}
+	in.rules = append(in.rules, r)
+	return r, nil
+}
+
+func (in *Injector) remove(id string) bool {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for i, r := range in.rules {
+		if r.ID == id {
+			in.rules = append(in.rules[:i:i], in.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// assignID names unnamed rules. in.mu must be held.
+func (in *Injector) assignID(r *Rule) {
+	if r.ID == "" {
+		in.nextID++
+		r.ID = "rule-" + strconv.Itoa(in.nextID)
+	}
+}
+
+// match returns the first rule that applies to the request, after the
+// rule's percentage is rolled. A rule that loses its roll is skipped, so
+// a later rule can still apply.
+func (in *Injector) match(r *http.Request, pattern string) (Rule, bool) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	if len(in.rules) == 0 && len(in.fixed) == 0 {
+		return Rule{}, false
+	}
+	variant := in.VariantOf(r)
+	for _, rules := range [][]Rule{in.rules, in.fixed} {
+		for _, rule := range rules {
+			if !rule.matches(r, pattern, variant) {
+				continue
+			}
+			if rule.Percent > 0 && rand.Float64()*100 >= rule.Percent {
+				continue
+			}
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (in *Injector) wrap(pattern string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Match on the pattern the client requested. It differs from
+		// pattern when the root API hands a request to a variant.
+		requested := pattern
+		if m := route.MatchFromContext(r.Context()); m != nil && m.Pattern != "" {
+			requested = m.Pattern
+		}
+		rule, ok := in.match(r, requested)
+		if !ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if rule.Latency != nil {
+			injections.WithLabelValues(rule.ID, "latency").Inc()
+			select {
+			case <-time.After(rule.Latency.Sample()):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if rule.Abort {
+			injections.WithLabelValues(rule.ID, "abort").Inc()
+			// The server closes the connection, or resets the HTTP/2 stream,
+			// without a response.
+			panic(http.ErrAbortHandler)
+		}
+		if rule.Status != 0 {
+			injections.WithLabelValues(rule.ID, "status").Inc()
+			w.Header().Set("X-Kuard-Fault", rule.ID)
+			http.Error(w, "injected fault "+rule.ID, rule.Status)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// Router wraps r so requests to routes registered through it are checked
+// against the rules.
+func (in *Injector) Router(r route.Router) route.Router {
+	return route.Wrap(r, func(_, pattern string, h http.Handler) http.Handler { return in.wrap(pattern, h) })
+}
+
+// AddRoutes registers the rules API. Register it directly on the router, not
+// through Router, so a rule can't lock the API itself out.
+func (in *Injector) AddRoutes(r route.Router, base string) {
+	r.GET(base, route.Describe(http.HandlerFunc(in.APIGet), route.Meta{
+		Summary:  "Fault injection rules in match order",
+		Response: []Rule{},
+	}))
+	r.POST(base, route.Describe(http.HandlerFunc(in.APIPost), route.Meta{
+		Summary:  "Append a fault injection rule",
+		Request:  Rule{},
+		Response: Rule{},
+	}))
+	r.PUT(base, route.Describe(http.HandlerFunc(in.APIPut), route.Meta{
+		Summary:  "Replace all fault injection rules",
+		Request:  []Rule{},
+		Response: []Rule{},
+	}))
+	r.DELETE(base, route.Describe(http.HandlerFunc(in.APIDeleteAll), route.Meta{
+		Summary: "Remove all fault injection rules",
+	}))
+	r.DELETE(base+"/:id", route.Describe(http.HandlerFunc(in.APIDelete), route.Meta{
+		Summary: "Remove a fault injection rule",
+	}))
+}
@@ -0,0 +1,236 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package faults
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+func newServer(in *Injector) *httptest.Server {
+	mux := http.NewServeMux()
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	r := in.Router(muxRouter{mux})
+	r.GET("/a/pageinfo", ok)
+	r.GET("/b/pageinfo", ok)
+	r.POST("/a/keygen", ok)
+	in.AddRoutes(muxRouter{mux}, "/faults")
+	return httptest.NewServer(mux)
+}
+
+// muxRouter adapts a ServeMux, enough for patterns without parameters plus
+// the one /faults/:id route.
+type muxRouter struct{ mux *http.ServeMux }
+
+func (m muxRouter) handle(method, pattern string, h http.Handler) {
+	if pattern == "/faults/:id" {
+		m.mux.Handle(method+" /faults/{id}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := route.WithParams(r.Context(), route.Params{"id": r.PathValue("id")})
+			h.ServeHTTP(w, r.WithContext(ctx))
+		}))
+		return
+	}
+	m.mux.Handle(method+" "+pattern, h)
+}
+
+func (m muxRouter) GET(p string, h http.Handler)    { m.handle("GET", p, h) }
+func (m muxRouter) POST(p string, h http.Handler)   { m.handle("POST", p, h) }
+func (m muxRouter) PUT(p string, h http.Handler)    { m.handle("PUT", p, h) }
+func (m muxRouter) PATCH(p string, h http.Handler)  { m.handle("PATCH", p, h) }
+func (m muxRouter) DELETE(p string, h http.Handler) { m.handle("DELETE", p, h) }
+
+func do(t *testing.T, method, url, body string, header ...string) *http.Response {
+	t.Helper()
+	req, _ := http.NewRequest(method, url, bytes.NewBufferString(body))
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestMatching(t *testing.T) {
+	in := New()
+	srv := newServer(in)
+	defer srv.Close()
+
+	if resp := do(t, "POST", srv.URL+"/faults", `{"id":"bad","route":"/*/pageinfo","header":"X-Fault=yes","status":503}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("add rule: %d", resp.StatusCode)
+	}
+	if resp := do(t, "POST", srv.URL+"/faults", `{"id":"bad","status":500}`); resp.StatusCode != http.StatusConflict {
+		t.Fatalf("duplicate id: %d, want 409", resp.StatusCode)
+	}
+	if err := in.SetRules([]Rule{{ID: "x", Status: 500}, {ID: "x", Status: 500}}); !errors.Is(err, ErrDuplicateID) {
+		t.Fatalf("SetRules with duplicate ids: %v", err)
+	}
+	if resp := do(t, "POST", srv.URL+"/faults", `{"status":500,"method":"POST"}`); resp.StatusCode != http.StatusOK {
+		t.Fatalf("add unnamed rule: %d", resp.StatusCode)
+	}
+	if resp := do(t, "POST", srv.URL+"/faults", `{"route":"/a/*"}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("rule without a fault: %d, want 400", resp.StatusCode)
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		header       []string
+		want         int
+	}{
+		{"GET", "/a/pageinfo", nil, 200},
+		{"GET", "/a/pageinfo", []string{"X-Fault", "no"}, 200},
+		{"GET", "/b/pageinfo", []string{"X-Fault", "yes"}, 503},
+		{"POST", "/a/keygen", nil, 500},
+	} {
+		resp := do(t, tc.method, srv.URL+tc.path, "", tc.header...)
+		if resp.StatusCode != tc.want {
+			t.Fatalf("%s %s %v: %d, want %d", tc.method, tc.path, tc.header, resp.StatusCode, tc.want)
+		}
+	}
+	if resp := do(t, "GET", srv.URL+"/b/pageinfo", "", "X-Fault", "yes"); resp.Header.Get("X-Kuard-Fault") != "bad" {
+		t.Fatalf("X-Kuard-Fault %q, want bad", resp.Header.Get("X-Kuard-Fault"))
+	}
+
+	if resp := do(t, "DELETE", srv.URL+"/faults/rule-1", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete: %d", resp.StatusCode)
+	}
+	if resp := do(t, "POST", srv.URL+"/a/keygen", ""); resp.StatusCode != 200 {
+		t.Fatalf("after delete: %d", resp.StatusCode)
+	}
+}
+
+func TestLatencyJSON(t *testing.T) {
+	in := New()
+	srv := newServer(in)
+	defer srv.Close()
+
+	body := `{"id":"slow","route":"/a/pageinfo","latency":{"distribution":"uniform","min":"30ms","max":"40ms"}}`
+	if resp := do(t, "POST", srv.URL+"/faults", body); resp.StatusCode != http.StatusOK {
+		t.Fatalf("add rule: %d", resp.StatusCode)
+	}
+	if resp := do(t, "POST", srv.URL+"/faults", `{"latency":{"delay":"soon"}}`); resp.StatusCode != http.StatusBadRequest {
+		t.Fatalf("bad duration: %d, want 400", resp.StatusCode)
+	}
+	if l := in.Rules()[0].Latency; l.Min != 30*time.Millisecond || l.Max != 40*time.Millisecond {
+		t.Fatalf("latency decoded as %+v", l)
+	}
+
+	start := time.Now()
+	do(t, "GET", srv.URL+"/a/pageinfo", "")
+	if d := time.Since(start); d < 30*time.Millisecond {
+		t.Fatalf("request took %v, want at least 30ms", d)
+	}
+
+	out, err := json.Marshal(in.Rules()[0].Latency)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if want := `{"distribution":"uniform","min":"30ms","max":"40ms"}`; string(out) != want {
+		t.Fatalf("marshal = %s, want %s", out, want)
+	}
+}
+
+func TestFixedRulesAndRolls(t *testing.T) {
+	in := New()
+	srv := newServer(in)
+	defer srv.Close()
+
+	if err := in.SetFixedRules([]Rule{{ID: "fixed", Status: 502}}); err != nil {
+		t.Fatalf("SetFixedRules: %v", err)
+	}
+	// Practically never wins its roll, so the fixed rule applies.
+	if err := in.SetRules([]Rule{{ID: "rare", Route: "/a/*", Status: 503, Percent: 1e-9}}); err != nil {
+		t.Fatalf("SetRules: %v", err)
+	}
+	if resp := do(t, "GET", srv.URL+"/a/pageinfo", ""); resp.StatusCode != 502 || resp.Header.Get("X-Kuard-Fault") != "fixed" {
+		t.Fatalf("status %d from %q, want 502 from the fixed rule", resp.StatusCode, resp.Header.Get("X-Kuard-Fault"))
+	}
+	if len(in.Rules()) != 1 {
+		t.Fatalf("fixed rules listed with the API's: %+v", in.Rules())
+	}
+
+	if resp := do(t, "DELETE", srv.URL+"/faults", ""); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("delete all: %d", resp.StatusCode)
+	}
+	if resp := do(t, "GET", srv.URL+"/b/pageinfo", ""); resp.StatusCode != 502 {
+		t.Fatalf("fixed rule removed through the API: %d", resp.StatusCode)
+	}
+}
+
+func TestVariantAndAbort(t *testing.T) {
+	in := New()
+	in.VariantOf = func(r *http.Request) string { return r.URL.Query().Get("v") }
+	if err := in.SetRules([]Rule{{Variant: "canary", Abort: true}}); err != nil {
+		t.Fatalf("SetRules: %v", err)
+	}
+	srv := newServer(in)
+	defer srv.Close()
+
+	if resp := do(t, "GET", srv.URL+"/a/pageinfo?v=stable", ""); resp.StatusCode != 200 {
+		t.Fatalf("other variant: %d", resp.StatusCode)
+	}
+	if _, err := http.Get(srv.URL + "/a/pageinfo?v=canary"); err == nil {
+		t.Fatalf("expected aborted connection")
+	}
+}
+
+func TestLatency(t *testing.T) {
+	fixed := &Latency{Distribution: DistFixed, Delay: 20 * time.Millisecond}
+	if d := fixed.Sample(); d != 20*time.Millisecond {
+		t.Fatalf("fixed sample %v", d)
+	}
+	uniform := &Latency{Distribution: DistUniform, Min: time.Millisecond, Max: 2 * time.Millisecond}
+	normal := &Latency{Distribution: DistNormal, Mean: time.Millisecond, StdDev: 10 * time.Millisecond}
+	for range 1000 {
+		if d := uniform.Sample(); d < time.Millisecond || d > 2*time.Millisecond {
+			t.Fatalf("uniform sample %v out of range", d)
+		}
+		if d := normal.Sample(); d < 0 {
+			t.Fatalf("normal sample %v is negative", d)
+		}
+	}
+
+	// About 1% of long-tail samples should exceed p99.
+	tail := &Latency{Distribution: DistLongTail, P50: time.Millisecond, P99: 100 * time.Millisecond}
+	over, under := 0, 0
+	for range 10000 {
+		d := tail.Sample()
+		if d > tail.P99 {
+			over++
+		}
+		if d < tail.P50 {
+			under++
+		}
+	}
+	if over < 50 || over > 200 || under < 4500 || under > 5500 {
+		t.Fatalf("longtail: %d above p99, %d below p50 of 10000", over, under)
+	}
+
+	bad := Rule{ID: "x", Latency: &Latency{Distribution: DistLongTail, P50: time.Second}}
+	if bad.Validate() == nil {
+		t.Fatalf("longtail without p99 should not validate")
+	}
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package faults
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/apiutils"
+)
+
+// Latency distributions.
+const (
+	DistFixed    = "fixed"
+	DistUniform  = "uniform"
+	DistNormal   = "normal"
+	DistLongTail = "longtail"
+)
+
+// Rule injects a fault into matching requests. Empty match fields match
+// anything.
+type Rule struct {
+	ID string `json:"id"`
+
+	Method  string `json:"method,omitempty"`
+	Route   string `json:"route,omitempty"` // route pattern, or a glob of one, e.g. /*/pageinfo
+	Variant string `json:"variant,omitempty"`
+	Header  string `json:"header,omitempty"` // Name, or Name=value
+
+	// Percent of matching requests to inject into, 0-100. 0 means all.
+	Percent float64 `json:"percent,omitempty"`
+
+	Latency *Latency `json:"latency,omitempty"`
+	Status  int      `json:"status,omitempty"`
+	Abort   bool     `json:"abort,omitempty"` // drop the connection without a response
+}
+
+// Latency describes how long to delay a response.
+type Latency struct {
+	// Distribution is fixed (Delay), uniform (Min to Max), normal (Mean,
+	// StdDev) or longtail: log-normal with the given median (P50) and 99th
+	// percentile (P99).
+	Distribution string        `json:"distribution"`
+	Delay        time.Duration `json:"delay,omitempty"`
+	Min          time.Duration `json:"min,omitempty"`
+	Max          time.Duration `json:"max,omitempty"`
+	Mean         time.Duration `json:"mean,omitempty"`
+	StdDev       time.Duration `json:"stddev,omitempty" mapstructure:"stddev"`
+	P50          time.Duration `json:"p50,omitempty"`
+	P99          time.Duration `json:"p99,omitempty"`
+}
+
+// z99 is the standard normal 99th percentile.
+const z99 = 2.3263
+
+// Validate reports rules that can never inject anything or are malformed.
+func (r *Rule) Validate() error {
+	var errs []error
+	if r.Latency == nil && r.Status == 0 && !r.Abort {
+		errs = append(errs, errors.New("needs latency, status or abort"))
+	}
+	if r.Status != 0 && (r.Status < 100 || r.Status > 599) {
+		errs = append(errs, fmt.Errorf("status %d out of range", r.Status))
+	}
+	if r.Percent < 0 || r.Percent > 100 {
+		errs = append(errs, errors.New("percent must be between 0 and 100"))
+	}
+	if _, err := path.Match(r.Route, ""); err != nil {
+		errs = append(errs, fmt.Errorf("route: %w", err))
+	}
+	if r.Latency != nil {
+		if err := r.Latency.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("fault rule %q: %w", r.ID, err)
+	}
+	return nil
+}
+
+// latencyJSON is the JSON form of Latency.
+type latencyJSON struct {
+	Distribution string            `json:"distribution"`
+	Delay        apiutils.Duration `json:"delay,omitempty"`
+	Min          apiutils.Duration `json:"min,omitempty"`
+	Max          apiutils.Duration `json:"max,omitempty"`
+	Mean         apiutils.Duration `json:"mean,omitempty"`
+	StdDev       apiutils.Duration `json:"stddev,omitempty"`
+	P50          apiutils.Duration `json:"p50,omitempty"`
+	P99          apiutils.Duration `json:"p99,omitempty"`
+}
+
+// MarshalJSON writes the durations as strings such as "150ms".
+func (l Latency) MarshalJSON() ([]byte, error) {
+	return json.Marshal(latencyJSON{
+		Distribution: l.Distribution,
+		Delay:        apiutils.Duration(l.Delay),
+		Min:          apiutils.Duration(l.Min),
+		Max:          apiutils.Duration(l.Max),
+		Mean:         apiutils.Duration(l.Mean),
+		StdDev:       apiutils.Duration(l.StdDev),
+		P50:          apiutils.Duration(l.P50),
+		P99:          apiutils.Duration(l.P99),
+	})
+}
+
+// UnmarshalJSON reads durations written as strings such as "150ms".
+func (l *Latency) UnmarshalJSON(b []byte) error {
+	var j latencyJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	*l = Latency{
+		Distribution: j.Distribution,
+		Delay:        time.Duration(j.Delay),
+		Min:          time.Duration(j.Min),
+		Max:          time.Duration(j.Max),
+		Mean:         time.Duration(j.Mean),
+		StdDev:       time.Duration(j.StdDev),
+		P50:          time.Duration(j.P50),
+		P99:          time.Duration(j.P99),
+	}
+	return nil
+}
+
+func (l *Latency) validate() error {
+	for _, d := range []time.Duration{l.Delay, l.Min, l.Max, l.Mean, l.StdDev, l.P50, l.P99} {
+		if d < 0 {
+			return errors.New("latency: durations must not be negative")
+		}
+	}
+	switch l.Distribution {
+	case DistFixed, "":
+	case DistUniform:
+		if l.Max < l.Min {
+			return errors.New("latency: max must not be less than min")
+		}
+	case DistNormal:
+	case DistLongTail:
+		if l.P50 <= 0 || l.P99 < l.P50 {
+			return errors.New("latency: longtail needs 0 < p50 <= p99")
+		}
+	default:
+		return fmt.Errorf("latency: unknown distribution %q", l.Distribution)
+	}
+	return nil
+}
+
+// Sample draws a delay.
+func (l *Latency) Sample() time.Duration {
+	var d float64
+	switch l.Distribution {
+	case DistUniform:
+		d = float64(l.Min) + rand.Float64()*float64(l.Max-l.Min)
+	case DistNormal:
+		d = float64(l.Mean) + rand.NormFloat64()*float64(l.StdDev)
+	case DistLongTail:
+		mu := math.Log(float64(l.P50))
+		sigma := (math.Log(float64(l.P99)) - mu) / z99
+		d = math.Exp(mu + sigma*rand.NormFloat64())
+	default:
+		d = float64(l.Delay)
+	}
+	return time.Duration(max(d, 0))
+}
+
+// matches reports whether the rule applies to a request for pattern served
+// by variant.
+func (r *Rule) matches(req *http.Request, pattern, variant string) bool {
+	if r.Method != "" && !strings.EqualFold(r.Method, req.Method) {
+		return false
+	}
+	if r.Route != "" {
+		if ok, _ := path.Match(r.Route, pattern); !ok {
+			return false
+		}
+	}
+	if r.Variant != "" && r.Variant != variant {
+		return false
+	}
+	if r.Header != "" {
+		name, value, hasValue := strings.Cut(r.Header, "=")
+		got, present := req.Header[http.CanonicalHeaderKey(name)]
+		if !present || (hasValue && (len(got) == 0 || got[0] != value)) {
+			return false
+		}
+	}
+	return true
+}
@@ -25,13 +25,29 @@ type Entry struct {
 	Err     string    `json:"err,omitempty"`
 }
 
+// Listing is returned from a GET to this API endpoint.
+type Listing struct {
+	Cwd      string  `json:"cwd"`
+	Entries  []Entry `json:"entries"`
+	Total    int     `json:"total"`
+	Returned int     `json:"returned"`
+	Offset   int     `json:"offset"`
+	Limit    int     `json:"limit"`
+	HasMore  bool    `json:"hasMore"`
+	Q        string  `json:"q"`
+}
+
 type API struct{}
 
 func New() *API { return &API{} }
 
 func (a *API) AddRoutes(r route.Router, base string) {
 	// Support both /fsapi and /fsapi/ plus wildcard for SPA convenience.
-	r.GET(base, http.HandlerFunc(a.handleList))
+	r.GET(base, route.Describe(http.HandlerFunc(a.handleList), route.Meta{
+		Summary:  "List a directory on the server filesystem",
+		Query:    []string{"path", "q", "limit", "offset"},
+		Response: Listing{},
+	}))
 	r.GET(base+"/", http.HandlerFunc(a.handleList))
 	r.GET(base+"/*filepath", http.HandlerFunc(a.handleList))
 }
@@ -113,14 +129,14 @@ func (a *API) handleList(w http.ResponseWriter, r *http.Request) {
 	hasMore := end < total
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]any{
-		"cwd":      abs,
-		"entries":  page,
-		"total":    total,
-		"returned": len(page),
-		"offset":   offset,
-		"limit":    limit,
-		"hasMore":  hasMore,
-		"q":        q,
+	json.NewEncoder(w).Encode(Listing{
+		Cwd:      abs,
+		Entries:  page,
+		Total:    total,
+		Returned: len(page),
+		Offset:   offset,
+		Limit:    limit,
+		HasMore:  hasMore,
+		Q:        q,
 	})
 }
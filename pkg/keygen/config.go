@@ -67,7 +67,9 @@ func (kg *KeyGen) BindConfig(v *viper.Viper, fs *pflag.FlagSet) {
 }
 
 func (kg *KeyGen) LoadConfig(c Config) {
+	kg.mu.Lock()
 	kg.config = c
+	kg.mu.Unlock()
 
 	kg.Restart()
 }
@@ -43,6 +43,11 @@ type KeyGen struct {
 	cancelFunc     context.CancelFunc
 }
 
+func init() {
+	prometheus.MustRegister(keygenKeysGenerated)
+	prometheus.MustRegister(keygenActive)
+}
+
 var (
 	keygenKeysGenerated = prometheus.NewCounter(prometheus.CounterOpts{
 		Namespace: "kuard",
@@ -62,15 +67,19 @@ func New() *KeyGen {
 	kg := &KeyGen{
 		history: []History{},
 	}
-	// Register metrics (ignore duplicate registration errors in hot-reload scenarios)
-	prometheus.MustRegister(keygenKeysGenerated)
-	prometheus.MustRegister(keygenActive)
 	return kg
 }
 
 func (kg *KeyGen) AddRoutes(router route.Router, base string) {
-	router.GET(base, http.HandlerFunc(kg.APIGet))
-	router.PUT(base, http.HandlerFunc(kg.APIPut))
+	router.GET(base, route.Describe(http.HandlerFunc(kg.APIGet), route.Meta{
+		Summary:  "KeyGen workload config and recent output",
+		Response: KeyGenStatus{},
+	}))
+	router.PUT(base, route.Describe(http.HandlerFunc(kg.APIPut), route.Meta{
+		Summary:  "Reconfigure and restart the KeyGen workload",
+		Request:  Config{},
+		Response: KeyGenStatus{},
+	}))
 }
 
 func (kg *KeyGen) Restart() {
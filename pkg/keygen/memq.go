@@ -22,9 +22,18 @@ import (
 	"os"
 	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/memq"
 	"github.com/kubernetes-up-and-running/kuard/pkg/memq/client"
+	"github.com/kubernetes-up-and-running/kuard/pkg/tracing"
 )
 
+var tracer = otel.Tracer("github.com/kubernetes-up-and-running/kuard/pkg/keygen")
+
 type memQWorker struct {
 	c    Config
 	ctx  context.Context
@@ -47,7 +56,7 @@ func newMemQWorker(ctx context.Context, c Config, out func(string)) *memQWorker
 func (w *memQWorker) startWork() {
 	w.log("MemQ Worker starting")
 	for !w.isDone() {
-		m, err := w.memq.Dequeue(w.c.MemQQueue)
+		m, err := w.memq.Dequeue(w.ctx, w.c.MemQQueue)
 		if err != nil {
 			w.logf("Error talking to server: %v. Retrying after 1s.", err)
 			time.Sleep(time.Second)
@@ -64,10 +73,25 @@ func (w *memQWorker) startWork() {
 			continue
 		}
 
-		w.itemDone(generateKey())
+		w.process(m)
 	}
 }
 
+// process generates a key for m, continuing the trace of the producer that
+// enqueued it.
+func (w *memQWorker) process(m *memq.Message) {
+	ctx := tracing.Extract(w.ctx, propagation.MapCarrier(m.Trace))
+	_, span := tracer.Start(ctx, "keygen process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.destination.name", w.c.MemQQueue),
+			attribute.String("messaging.message.id", m.ID),
+		))
+	defer span.End()
+
+	w.itemDone(generateKey())
+}
+
 func (w *memQWorker) isDone() bool {
 	select {
 	case <-w.ctx.Done():
@@ -0,0 +1,162 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package lifecycle runs a set of HTTP listeners as a unit. All listeners are
+// bound before any starts serving, and they are shut down together, within a
+// shared grace period, when the run context is cancelled or any one of them
+// fails.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Listener is a single server owned by a Manager.
+type Listener struct {
+	// Name identifies the listener in logs, e.g. "http" or "https".
+	Name string
+	// Server is the server to run. Server.Addr is the address to bind.
+	Server *http.Server
+	// TLS serves HTTPS. Certificates come from CertFile/KeyFile, or from
+	// Server.TLSConfig when both are empty.
+	TLS      bool
+	CertFile string
+	KeyFile  string
+}
+
+// Manager owns a set of listeners.
+type Manager struct {
+	grace     time.Duration
+	listeners []Listener
+
+	mu    sync.Mutex
+	addrs map[string]net.Addr
+}
+
+// New returns a Manager that allows in-flight requests up to grace to finish
+// on shutdown.
+func New(grace time.Duration) *Manager {
+	return &Manager{grace: grace, addrs: map[string]net.Addr{}}
+}
+
+// Add registers l. It must be called before Run.
+func (m *Manager) Add(l Listener) {
+	m.listeners = append(m.listeners, l)
+}
+
+// Addr returns the bound address of the named listener once Run has started
+// it, or nil.
+func (m *Manager) Addr(name string) net.Addr {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.addrs[name]
+}
+
+// SetKeepAlivesEnabled toggles HTTP keep-alives on every listener. Disabling
+// them while draining makes clients reconnect, and so move to other
+// endpoints, instead of reusing connections to a terminating pod.
+func (m *Manager) SetKeepAlivesEnabled(v bool) {
+	for _, l := range m.listeners {
+		l.Server.SetKeepAlivesEnabled(v)
+	}
+}
+
+// Run binds and serves every listener, blocking until ctx is cancelled or a
+// listener fails. It then shuts all listeners down and returns the first
+// serve error together with any shutdown errors.
+func (m *Manager) Run(ctx context.Context) error {
+	lns := make([]net.Listener, 0, len(m.listeners))
+	for _, l := range m.listeners {
+		ln, err := net.Listen("tcp", l.Server.Addr)
+		if err != nil {
+			for _, open := range lns {
+				open.Close()
+			}
+			return fmt.Errorf("%s listener: %w", l.Name, err)
+		}
+		lns = append(lns, ln)
+		m.mu.Lock()
+		m.addrs[l.Name] = ln.Addr()
+		m.mu.Unlock()
+	}
+
+	failed := make(chan error, len(m.listeners))
+	var wg sync.WaitGroup
+	for i, l := range m.listeners {
+		ln := lns[i]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			slog.Info("listener started", "listener", l.Name, "addr", ln.Addr().String(), "tls", l.TLS)
+			var err error
+			if l.TLS {
+				err = l.Server.ServeTLS(ln, l.CertFile, l.KeyFile)
+			} else {
+				err = l.Server.Serve(ln)
+			}
+			if err != nil && !errors.Is(err, http.ErrServerClosed) {
+				slog.Error("listener failed", "listener", l.Name, "error", err)
+				failed <- fmt.Errorf("%s listener: %w", l.Name, err)
+			}
+		}()
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case runErr = <-failed:
+	}
+
+	errs := []error{runErr}
+	errs = append(errs, m.shutdown()...)
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// shutdown stops all listeners concurrently within the grace period.
+func (m *Manager) shutdown() []error {
+	slog.Info("shutting down listeners", "grace", m.grace.String())
+	ctx, cancel := context.WithTimeout(context.Background(), m.grace)
+	defer cancel()
+
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	for _, l := range m.listeners {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := l.Server.Shutdown(ctx)
+			if err != nil {
+				slog.Error("graceful shutdown failed; closing", "listener", l.Name, "error", err)
+				l.Server.Close()
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s listener: %w", l.Name, err))
+				mu.Unlock()
+			}
+			slog.Info("listener stopped", "listener", l.Name)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
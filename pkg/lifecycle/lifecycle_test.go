@@ -0,0 +1,70 @@
+package lifecycle
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestManagerRunAndShutdown(t *testing.T) {
+	release := make(chan struct{})
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("done"))
+	})
+	m := New(2 * time.Second)
+	m.Add(Listener{Name: "a", Server: &http.Server{Addr: "127.0.0.1:0", Handler: slow}})
+	m.Add(Listener{Name: "b", Server: &http.Server{Addr: "127.0.0.1:0", Handler: slow}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.Run(ctx) }()
+
+	var addr net.Addr
+	for i := 0; i < 100 && addr == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		addr = m.Addr("b")
+	}
+	if addr == nil || m.Addr("a") == nil {
+		t.Fatalf("listeners not started")
+	}
+
+	// An in-flight request must complete during the grace period.
+	got := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr.String())
+		if err != nil {
+			got <- 0
+			return
+		}
+		got <- resp.StatusCode
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	if code := <-got; code != http.StatusOK {
+		t.Fatalf("in-flight request failed: %d", code)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("run: %v", err)
+	}
+}
+
+func TestManagerBindFailure(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	m := New(time.Second)
+	m.Add(Listener{Name: "ok", Server: &http.Server{Addr: "127.0.0.1:0"}})
+	m.Add(Listener{Name: "taken", Server: &http.Server{Addr: ln.Addr().String()}})
+	if err := m.Run(context.Background()); err == nil {
+		t.Fatalf("expected bind error")
+	}
+}
@@ -0,0 +1,137 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memory
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Allocation kinds. Heap memory is Go managed. Anonymous mmap is outside the
+// Go heap, so it shows up in RSS but not in MemStats. shm is a file on the
+// /dev/shm tmpfs, charged to the container's memory cgroup as shmem.
+const (
+	KindHeap = "heap"
+	KindMmap = "mmap"
+	KindShm  = "shm"
+)
+
+// Allocation is a block of memory held until freed.
+type Allocation struct {
+	ID      int       `json:"id"`
+	Kind    string    `json:"kind"`
+	Bytes   int64     `json:"bytes"`
+	Created time.Time `json:"created"`
+	Leak    bool      `json:"leak"`           // made by the background leak
+	Path    string    `json:"path,omitempty"` // the shm file
+
+	data []byte
+}
+
+// allocate makes a block of size bytes of the given kind and touches every
+// page, so it is resident and not just reserved.
+func allocate(kind string, size int64, shmDir string) (*Allocation, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("size must not be negative")
+	}
+	a := &Allocation{Kind: kind, Bytes: size, Created: time.Now()}
+	var err error
+	switch kind {
+	case KindHeap:
+		a.data = make([]byte, size)
+	case KindMmap:
+		if size > 0 {
+			a.data, err = syscall.Mmap(-1, 0, int(size), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+		}
+	case KindShm:
+		err = a.mapShm(shmDir)
+	default:
+		return nil, fmt.Errorf("unknown kind %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	touch(a.data)
+	return a, nil
+}
+
+func (a *Allocation) mapShm(dir string) error {
+	f, err := os.CreateTemp(dir, "kuard-mem-*")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	a.Path = f.Name()
+	if a.Bytes == 0 {
+		return nil
+	}
+	if err = f.Truncate(a.Bytes); err == nil {
+		a.data, err = syscall.Mmap(int(f.Fd()), 0, int(a.Bytes), syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	}
+	if err != nil {
+		os.Remove(a.Path)
+	}
+	return err
+}
+
+var pageSize = os.Getpagesize()
+
+func touch(b []byte) {
+	for i := 0; i < len(b); i += pageSize {
+		b[i] = 'x'
+	}
+}
+
+// free releases the block. Heap memory is only returned once the GC runs.
+func (a *Allocation) free() error {
+	var err error
+	if a.Kind != KindHeap && a.data != nil {
+		err = syscall.Munmap(a.data)
+	}
+	a.data = nil
+	if a.Path != "" {
+		if rerr := os.Remove(a.Path); err == nil {
+			err = rerr
+		}
+	}
+	return err
+}
+
+// availableMemory returns the node's MemAvailable from /proc/meminfo, or its
+// total memory if that can't be read.
+func availableMemory() int64 {
+	if b, err := os.ReadFile("/proc/meminfo"); err == nil {
+		for _, line := range strings.Split(string(b), "\n") {
+			if rest, ok := strings.CutPrefix(line, "MemAvailable:"); ok {
+				kb, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimSpace(rest), " kB"), 10, 64)
+				if err == nil {
+					return kb << 10
+				}
+			}
+		}
+	}
+	var info syscall.Sysinfo_t
+	if err := syscall.Sysinfo(&info); err != nil {
+		return math.MaxInt64
+	}
+	return int64(info.Totalram) * int64(info.Unit)
+}
@@ -17,38 +17,103 @@ limitations under the License.
 package memory
 
 import (
+	"fmt"
+	"math"
 	"net/http"
 	"runtime"
 	"runtime/debug"
 	"strconv"
+	"sync"
 
 	"github.com/kubernetes-up-and-running/kuard/pkg/apiutils"
+	"github.com/kubernetes-up-and-running/kuard/pkg/cgroup"
 	"github.com/kubernetes-up-and-running/kuard/pkg/route"
 )
 
 type MemoryAPI struct {
-	leaks [][]byte
+	cg     *cgroup.Reader
+	shmDir string
+
+	mu        sync.Mutex
+	c         Config
+	autoLimit *AutoLimit
+	leaks     []*Allocation // in ID order
+	nextID    int
+	leak      *Leak
 }
 
 // MemoryStatus is returned from a GET to this API endpoing
 type MemoryStatus struct {
 	MemStats runtime.MemStats `json:"memStats"`
+	// Cgroup is the container's usage and limits as the kubelet sees them.
+	Cgroup      *cgroup.Stats `json:"cgroup,omitempty"`
+	CgroupError string        `json:"cgroupError,omitempty"`
+}
+
+// AllocStatus lists the memory held through this API.
+type AllocStatus struct {
+	Allocations []Allocation `json:"allocations"`
+	HeldBytes   int64        `json:"heldBytes"`
+	Leak        *Leak        `json:"leak,omitempty"`
 }
 
 func New() *MemoryAPI {
-	return &MemoryAPI{}
+	return &MemoryAPI{cg: cgroup.New(cgroup.DefaultRoot), shmDir: "/dev/shm"}
 }
 
 func (e *MemoryAPI) AddRoutes(r route.Router, base string) {
-	r.GET(base+"/api", http.HandlerFunc(e.APIGet))
-	r.POST(base+"/api/alloc", http.HandlerFunc(e.APIAlloc))
-	r.POST(base+"/api/clear", http.HandlerFunc(e.APIClear))
+	r.GET(base+"/api", route.Describe(http.HandlerFunc(e.APIGet), route.Meta{
+		Summary:  "Go runtime and cgroup memory statistics",
+		Response: MemoryStatus{},
+	}))
+	r.POST(base+"/api/alloc", route.Describe(http.HandlerFunc(e.APIAlloc), route.Meta{
+		Summary:  "Allocate and hold size bytes of heap, mmap or shm memory",
+		Query:    []string{"size", "kind"},
+		Response: Allocation{},
+	}))
+	r.POST(base+"/api/clear", route.Describe(http.HandlerFunc(e.APIClear), route.Meta{
+		Summary: "Stop any leak, release held memory and return it to the OS",
+	}))
+	r.GET(base+"/api/gc", route.Describe(http.HandlerFunc(e.APIGetGC), route.Meta{
+		Summary:  "GOGC and GOMEMLIMIT",
+		Response: GCSettings{},
+	}))
+	r.PUT(base+"/api/gc", route.Describe(http.HandlerFunc(e.APISetGC), route.Meta{
+		Summary:  "Set GOGC and GOMEMLIMIT",
+		Query:    []string{"gogc", "memlimit"},
+		Response: GCSettings{},
+	}))
+	r.POST(base+"/api/gc", route.Describe(http.HandlerFunc(e.APIForceGC), route.Meta{
+		Summary:  "Force a garbage collection, returning memory to the OS if release=true",
+		Query:    []string{"release"},
+		Response: GCResult{},
+	}))
+	r.GET(base+"/api/allocs", route.Describe(http.HandlerFunc(e.APIAllocs), route.Meta{
+		Summary:  "Held allocations and leak progress",
+		Response: AllocStatus{},
+	}))
+	r.DELETE(base+"/api/allocs/:id", route.Describe(http.HandlerFunc(e.APIFree), route.Meta{
+		Summary: "Free one allocation",
+	}))
+	r.POST(base+"/api/leak", route.Describe(http.HandlerFunc(e.APILeak), route.Meta{
+		Summary:  "Leak rate MB per second, up to max MB if set",
+		Query:    []string{"rate", "max", "kind"},
+		Response: AllocStatus{},
+	}))
+	r.DELETE(base+"/api/leak", route.Describe(http.HandlerFunc(e.APIStopLeak), route.Meta{
+		Summary: "Stop the leak, keeping what it allocated",
+	}))
 }
 
 func (e *MemoryAPI) APIGet(w http.ResponseWriter, _ *http.Request) {
 	resp := &MemoryStatus{}
 
 	runtime.ReadMemStats(&resp.MemStats)
+	cg, err := e.cg.Stats()
+	if err != nil {
+		resp.CgroupError = err.Error()
+	}
+	resp.Cgroup = cg
 
 	apiutils.ServeJSON(w, resp)
 }
@@ -61,20 +126,157 @@ func (m *MemoryAPI) APIAlloc(w http.ResponseWriter, r *http.Request) {
 	}
 
 	i, err := strconv.ParseInt(sSize, 10, 64)
-	if err != nil {
+	if err != nil || i < 0 {
 		http.Error(w, "bad size param", http.StatusBadRequest)
+		return
 	}
 
-	leak := make([]byte, i, i)
-	for i := 0; i < len(leak); i++ {
-		leak[i] = 'x'
+	kind := r.URL.Query().Get("kind")
+	if kind == "" {
+		kind = KindHeap
 	}
+	if limit := m.allocLimit(); i > limit {
+		http.Error(w, fmt.Sprintf("size exceeds the memory limit of %d bytes", limit), http.StatusBadRequest)
+		return
+	}
+	a, err := allocate(kind, i, m.shmDir)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	m.addLocked(a)
+	m.mu.Unlock()
+
+	apiutils.ServeJSON(w, a)
+}
 
-	m.leaks = append(m.leaks, leak)
+func (m *MemoryAPI) addLocked(a *Allocation) {
+	m.nextID++
+	a.ID = m.nextID
+	m.leaks = append(m.leaks, a)
 }
 
 func (m *MemoryAPI) APIClear(w http.ResponseWriter, _ *http.Request) {
+	m.mu.Lock()
+	m.stopLeakLocked()
+	for _, a := range m.leaks {
+		a.free()
+	}
 	m.leaks = nil
+	m.mu.Unlock()
+
 	runtime.GC()
 	debug.FreeOSMemory()
 }
+
+func (m *MemoryAPI) APIAllocs(w http.ResponseWriter, _ *http.Request) {
+	resp := &AllocStatus{Allocations: []Allocation{}}
+
+	m.mu.Lock()
+	for _, a := range m.leaks {
+		resp.Allocations = append(resp.Allocations, *a)
+		resp.HeldBytes += a.Bytes
+	}
+	if m.leak != nil {
+		l := *m.leak
+		resp.Leak = &l
+	}
+	m.mu.Unlock()
+
+	apiutils.ServeJSON(w, resp)
+}
+
+func (m *MemoryAPI) APIFree(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(route.Param(r, "id"))
+	if err != nil {
+		http.Error(w, "bad id", http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	var found *Allocation
+	for i, a := range m.leaks {
+		if a.ID == id {
+			found = a
+			m.leaks = append(m.leaks[:i:i], m.leaks[i+1:]...)
+			break
+		}
+	}
+	m.mu.Unlock()
+	if found == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err := found.free(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if found.Kind == KindHeap {
+		runtime.GC()
+		debug.FreeOSMemory()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *MemoryAPI) APILeak(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	l := Leak{Kind: q.Get("kind")}
+	if l.Kind == "" {
+		l.Kind = KindHeap
+	}
+	switch l.Kind {
+	case KindHeap, KindMmap, KindShm:
+	default:
+		http.Error(w, "bad kind param", http.StatusBadRequest)
+		return
+	}
+
+	var ok bool
+	if l.RateMB, ok = parseMB(q.Get("rate")); !ok || l.RateMB == 0 {
+		http.Error(w, "bad rate param", http.StatusBadRequest)
+		return
+	}
+	if s := q.Get("max"); s != "" {
+		if l.MaxMB, ok = parseMB(s); !ok {
+			http.Error(w, "bad max param", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if limit := m.allocLimit(); l.RateMB*mib*leakInterval.Seconds() > float64(limit) {
+		http.Error(w, fmt.Sprintf("rate exceeds the memory limit of %d bytes per step", limit), http.StatusBadRequest)
+		return
+	}
+
+	m.StartLeak(l)
+	m.APIAllocs(w, r)
+}
+
+// parseMB parses a leak rate or ceiling in MB. It must be between 0 and a
+// value whose size in bytes fits an int64. NaN fails both comparisons and
+// Inf the upper one.
+func parseMB(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil && v >= 0 && v <= math.MaxInt64/mib
+}
+
+// allocLimit is the largest allocation that could ever succeed: the cgroup
+// memory limit, or the memory available on the node without one. Larger
+// requests are rejected before allocating, because a heap allocation the
+// runtime can't satisfy is a fatal error that recover can't catch. Smaller
+// ones can still run the container out of memory, which is what this API is
+// for.
+func (m *MemoryAPI) allocLimit() int64 {
+	if cg, err := m.cg.Memory(); err == nil && cg.LimitBytes > 0 {
+		return cg.LimitBytes
+	}
+	return availableMemory()
+}
+
+func (m *MemoryAPI) APIStopLeak(w http.ResponseWriter, _ *http.Request) {
+	m.StopLeak()
+	w.WriteHeader(http.StatusNoContent)
+}
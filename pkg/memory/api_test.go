@@ -18,10 +18,18 @@ package memory
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime/debug"
 	"strconv"
 	"testing"
+	"time"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/cgroup"
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
 )
 
 func TestMemoryAPIAllocAndClear(t *testing.T) {
@@ -29,7 +37,7 @@ func TestMemoryAPIAllocAndClear(t *testing.T) {
 	r := httptest.NewRequest(http.MethodPost, "/mem/api/alloc?size=1024", nil)
 	w := httptest.NewRecorder()
 	m.APIAlloc(w, r)
-	if len(m.leaks) != 1 || len(m.leaks[0]) != 1024 {
+	if len(m.leaks) != 1 || len(m.leaks[0].data) != 1024 {
 		t.Fatalf("allocation failed")
 	}
 	cw := httptest.NewRecorder()
@@ -39,6 +47,101 @@ func TestMemoryAPIAllocAndClear(t *testing.T) {
 	}
 }
 
+func TestAllocKindsAndFree(t *testing.T) {
+	m := New()
+	m.shmDir = t.TempDir()
+
+	var ids []int
+	for _, kind := range []string{KindHeap, KindMmap, KindShm} {
+		w := httptest.NewRecorder()
+		m.APIAlloc(w, httptest.NewRequest(http.MethodPost, "/mem/api/alloc?size=10000&kind="+kind, nil))
+		var a Allocation
+		if err := json.Unmarshal(w.Body.Bytes(), &a); err != nil || a.Kind != kind || a.Bytes != 10000 {
+			t.Fatalf("alloc %s: %d %s", kind, w.Code, w.Body)
+		}
+		ids = append(ids, a.ID)
+	}
+	w := httptest.NewRecorder()
+	m.APIAlloc(w, httptest.NewRequest(http.MethodPost, "/mem/api/alloc?size=1&kind=stack", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unknown kind: %d, want 400", w.Code)
+	}
+	w = httptest.NewRecorder()
+	m.APIAlloc(w, httptest.NewRequest(http.MethodPost, "/mem/api/alloc?size=9223372036854775807", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("huge size: %d, want 400", w.Code)
+	}
+
+	shm := m.leaks[2].Path
+	if _, err := os.Stat(shm); err != nil {
+		t.Fatalf("shm file: %v", err)
+	}
+	if m.leaks[1].data[pageSize] != 'x' {
+		t.Fatalf("mmap pages not touched")
+	}
+
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "/mem/api/allocs/3", nil)
+	m.APIFree(w, r.WithContext(route.WithParams(r.Context(), route.Params{"id": strconv.Itoa(ids[2])})))
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("free: %d", w.Code)
+	}
+	if _, err := os.Stat(shm); !os.IsNotExist(err) {
+		t.Fatalf("shm file left behind: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	m.APIAllocs(w, httptest.NewRequest(http.MethodGet, "/mem/api/allocs", nil))
+	var st AllocStatus
+	json.Unmarshal(w.Body.Bytes(), &st)
+	if len(st.Allocations) != 2 || st.HeldBytes != 20000 {
+		t.Fatalf("after free %+v", st)
+	}
+}
+
+func TestLeakCeiling(t *testing.T) {
+	defer func(d time.Duration) { leakInterval = d }(leakInterval)
+	leakInterval = 10 * time.Millisecond
+
+	m := New()
+	w := httptest.NewRecorder()
+	m.APILeak(w, httptest.NewRequest(http.MethodPost, "/mem/api/leak?rate=100&max=3&kind=mmap", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("leak: %d %s", w.Code, w.Body)
+	}
+
+	var st AllocStatus
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		w = httptest.NewRecorder()
+		m.APIAllocs(w, httptest.NewRequest(http.MethodGet, "/mem/api/allocs", nil))
+		st = AllocStatus{}
+		json.Unmarshal(w.Body.Bytes(), &st)
+		if !st.Leak.Running {
+			break
+		}
+	}
+	if st.Leak.Running || st.Leak.Bytes != 3<<20 || st.HeldBytes != 3<<20 || !st.Allocations[0].Leak {
+		t.Fatalf("leak did not stop at its ceiling: %+v", st.Leak)
+	}
+
+	m.APIClear(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/mem/api/clear", nil))
+	if m.leaks != nil {
+		t.Fatalf("expected leaks cleared")
+	}
+
+	last := m.leak
+	for _, q := range []string{"rate=0", "rate=-1", "rate=NaN", "rate=Inf", "rate=1e300", "rate=1&max=NaN", "rate=1&max=+Inf", "rate=1&max=-1", "rate=1&max=1e300"} {
+		w = httptest.NewRecorder()
+		m.APILeak(w, httptest.NewRequest(http.MethodPost, "/mem/api/leak?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: %d, want 400", q, w.Code)
+		}
+	}
+	if m.leak != last {
+		t.Fatalf("rejected leak started: %+v", m.leak)
+	}
+}
+
 func TestMemoryAPIGet(t *testing.T) {
 	m := New()
 	w := httptest.NewRecorder()
@@ -55,6 +158,123 @@ func TestMemoryAPIGet(t *testing.T) {
 	}
 }
 
+func TestMemoryAPIGetCgroup(t *testing.T) {
+	root := t.TempDir()
+	for name, data := range map[string]string{
+		"cgroup.controllers": "memory\n",
+		"memory.current":     "1048576\n",
+		"memory.max":         "max\n",
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := New()
+	m.cg = cgroup.New(root)
+
+	w := httptest.NewRecorder()
+	m.APIGet(w, httptest.NewRequest(http.MethodGet, "/mem/api", nil))
+	var st MemoryStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	if st.Cgroup == nil || st.Cgroup.Memory.UsageBytes != 1048576 || st.Cgroup.Memory.LimitBytes != -1 {
+		t.Fatalf("cgroup %+v, error %q", st.Cgroup, st.CgroupError)
+	}
+
+	m.cg = cgroup.New(t.TempDir())
+	w = httptest.NewRecorder()
+	m.APIGet(w, httptest.NewRequest(http.MethodGet, "/mem/api", nil))
+	st = MemoryStatus{}
+	json.Unmarshal(w.Body.Bytes(), &st)
+	if st.Cgroup != nil || st.CgroupError == "" {
+		t.Fatalf("expected a cgroup error without cgroup files, got %+v", st)
+	}
+}
+
+func TestGCSettings(t *testing.T) {
+	defer debug.SetGCPercent(debug.SetGCPercent(100))
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(math.MaxInt64))
+
+	m := New()
+	w := httptest.NewRecorder()
+	m.APISetGC(w, httptest.NewRequest(http.MethodPut, "/mem/api/gc?gogc=50&memlimit=512MiB", nil))
+	var st GCSettings
+	json.Unmarshal(w.Body.Bytes(), &st)
+	if st.GOGC != 50 || st.MemoryLimitBytes != 512<<20 {
+		t.Fatalf("after set %+v", st)
+	}
+
+	w = httptest.NewRecorder()
+	m.APISetGC(w, httptest.NewRequest(http.MethodPut, "/mem/api/gc?gogc=off&memlimit=off", nil))
+	st = GCSettings{}
+	json.Unmarshal(w.Body.Bytes(), &st)
+	if st.GOGC != -1 || st.MemoryLimitBytes != math.MaxInt64 {
+		t.Fatalf("after off %+v", st)
+	}
+
+	// GOGC=0 collects continuously; it is not off.
+	w = httptest.NewRecorder()
+	m.APISetGC(w, httptest.NewRequest(http.MethodPut, "/mem/api/gc?gogc=0", nil))
+	st = GCSettings{}
+	json.Unmarshal(w.Body.Bytes(), &st)
+	if w.Code != http.StatusOK || st.GOGC != 0 {
+		t.Fatalf("after gogc=0: %d %+v", w.Code, st)
+	}
+
+	for _, q := range []string{"gogc=-5", "gogc=lots", "memlimit=1GB", "memlimit=-1", "memlimit=99999999TiB"} {
+		w = httptest.NewRecorder()
+		m.APISetGC(w, httptest.NewRequest(http.MethodPut, "/mem/api/gc?"+q, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Fatalf("%s: %d, want 400", q, w.Code)
+		}
+	}
+}
+
+func TestForceGC(t *testing.T) {
+	m := New()
+	w := httptest.NewRecorder()
+	m.APIForceGC(w, httptest.NewRequest(http.MethodPost, "/mem/api/gc?release=true", nil))
+	var res GCResult
+	json.Unmarshal(w.Body.Bytes(), &res)
+	if !res.Released || res.After.NumGC <= res.Before.NumGC {
+		t.Fatalf("gc result %+v", res)
+	}
+}
+
+func TestAutoGoMemLimit(t *testing.T) {
+	defer debug.SetMemoryLimit(debug.SetMemoryLimit(math.MaxInt64))
+	root := t.TempDir()
+	for name, data := range map[string]string{
+		"cgroup.controllers": "memory\n",
+		"memory.current":     "1048576\n",
+		"memory.max":         "1073741824\n",
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("GOMEMLIMIT", "")
+
+	m := New()
+	m.cg = cgroup.New(root)
+	m.SetConfig(Config{AutoGoMemLimit: true, GoMemLimitRatio: 0.75})
+	st := m.gcSettings()
+	if st.MemoryLimitBytes != 768<<20 || st.AutoLimit == nil || st.AutoLimit.CgroupLimitBytes != 1<<30 {
+		t.Fatalf("auto limit %+v", st)
+	}
+
+	// An explicit GOMEMLIMIT is left alone.
+	debug.SetMemoryLimit(math.MaxInt64)
+	t.Setenv("GOMEMLIMIT", "2GiB")
+	m = New()
+	m.cg = cgroup.New(root)
+	m.SetConfig(Config{AutoGoMemLimit: true, GoMemLimitRatio: 0.75})
+	if st := m.gcSettings(); st.MemoryLimitBytes != math.MaxInt64 || st.AutoLimit != nil {
+		t.Fatalf("GOMEMLIMIT from the environment overridden: %+v", st)
+	}
+}
+
 func FuzzMemoryAlloc(f *testing.F) {
 	f.Add("128")
 	f.Add("0")
@@ -72,3 +292,37 @@ func FuzzMemoryAlloc(f *testing.F) {
 		m.APIAlloc(w, req)
 	})
 }
+
+func TestAllocLimit(t *testing.T) {
+	root := t.TempDir()
+	for name, data := range map[string]string{
+		"cgroup.controllers": "memory\n",
+		"memory.current":     "1048576\n",
+		"memory.max":         "67108864\n",
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := New()
+	m.cg = cgroup.New(root)
+	if got := m.allocLimit(); got != 64<<20 {
+		t.Fatalf("limit %d, want the cgroup's 64MiB", got)
+	}
+
+	w := httptest.NewRecorder()
+	m.APIAlloc(w, httptest.NewRequest(http.MethodPost, "/mem/api/alloc?size=67108865", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("alloc above the cgroup limit: %d, want 400", w.Code)
+	}
+	w = httptest.NewRecorder()
+	m.APILeak(w, httptest.NewRequest(http.MethodPost, "/mem/api/leak?rate=65", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("leak step above the cgroup limit: %d, want 400", w.Code)
+	}
+
+	m.cg = cgroup.New(t.TempDir())
+	if got := m.allocLimit(); got <= 0 {
+		t.Fatalf("limit %d without a cgroup, want available memory", got)
+	}
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memory
+
+import (
+	"log/slog"
+	"os"
+	"runtime/debug"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Config controls how the Go runtime's memory limit is set at startup.
+type Config struct {
+	// AutoGoMemLimit sets GOMEMLIMIT to GoMemLimitRatio of the cgroup memory
+	// limit, leaving the rest as headroom for memory outside the Go heap.
+	AutoGoMemLimit  bool    `json:"autoGoMemLimit" mapstructure:"auto-gomemlimit"`
+	GoMemLimitRatio float64 `json:"goMemLimitRatio" mapstructure:"gomemlimit-ratio"`
+}
+
+// AutoLimit records a GOMEMLIMIT derived from the cgroup limit.
+type AutoLimit struct {
+	CgroupLimitBytes int64   `json:"cgroupLimitBytes"`
+	Ratio            float64 `json:"ratio"`
+	LimitBytes       int64   `json:"limitBytes"`
+}
+
+// SetConfig applies c. An explicit GOMEMLIMIT in the environment wins over
+// the automatic limit.
+func (m *MemoryAPI) SetConfig(c Config) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.c = c
+	if !c.AutoGoMemLimit {
+		return
+	}
+	if os.Getenv("GOMEMLIMIT") != "" {
+		slog.Info("GOMEMLIMIT set in the environment; not deriving it from the cgroup limit")
+		return
+	}
+	cg, err := m.cg.Memory()
+	if err != nil || cg.LimitBytes <= 0 {
+		slog.Info("no cgroup memory limit; GOMEMLIMIT left unset", "error", err)
+		return
+	}
+	limit := int64(float64(cg.LimitBytes) * c.GoMemLimitRatio)
+	debug.SetMemoryLimit(limit)
+	m.autoLimit = &AutoLimit{CgroupLimitBytes: cg.LimitBytes, Ratio: c.GoMemLimitRatio, LimitBytes: limit}
+	slog.Info("GOMEMLIMIT set from cgroup memory limit", "limit", limit, "cgroupLimit", cg.LimitBytes, "ratio", c.GoMemLimitRatio)
+}
+
+func BindConfig(v *viper.Viper, fs *pflag.FlagSet) {
+	fs.Bool("mem-auto-gomemlimit", false, "Set GOMEMLIMIT from the container's cgroup memory limit")
+	fs.Float64("mem-gomemlimit-ratio", 0.9, "Share of the cgroup memory limit to use as GOMEMLIMIT, leaving the rest as headroom")
+
+	fs.VisitAll(func(f *pflag.Flag) {
+		name := strings.TrimPrefix(f.Name, "mem-")
+		if name != f.Name {
+			v.BindPFlag("mem."+name, f)
+		}
+	})
+}
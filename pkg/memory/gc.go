@@ -0,0 +1,174 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memory
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/apiutils"
+)
+
+// GCSettings are the Go runtime's collector settings.
+type GCSettings struct {
+	// GOGC is the GC percentage, or -1 when the collector is off.
+	GOGC int64 `json:"gogc"`
+	// MemoryLimitBytes is GOMEMLIMIT, math.MaxInt64 when there is none.
+	MemoryLimitBytes int64      `json:"memoryLimitBytes"`
+	AutoLimit        *AutoLimit `json:"autoLimit,omitempty"`
+}
+
+// GCStats are the heap figures a collection changes.
+type GCStats struct {
+	HeapAlloc    uint64 `json:"heapAlloc"`
+	HeapInuse    uint64 `json:"heapInuse"`
+	HeapIdle     uint64 `json:"heapIdle"`
+	HeapReleased uint64 `json:"heapReleased"`
+	HeapObjects  uint64 `json:"heapObjects"`
+	Sys          uint64 `json:"sys"`
+	NumGC        uint32 `json:"numGC"`
+}
+
+// GCResult is returned from a forced collection.
+type GCResult struct {
+	Before   GCStats       `json:"before"`
+	After    GCStats       `json:"after"`
+	Duration time.Duration `json:"duration"`
+	Released bool          `json:"released"` // memory was returned to the OS
+}
+
+func (m *MemoryAPI) gcSettings() GCSettings {
+	samples := []metrics.Sample{{Name: "/gc/gogc:percent"}, {Name: "/gc/gomemlimit:bytes"}}
+	metrics.Read(samples)
+	s := GCSettings{
+		GOGC:             int64(samples[0].Value.Uint64()),
+		MemoryLimitBytes: int64(samples[1].Value.Uint64()),
+	}
+
+	m.mu.Lock()
+	if m.autoLimit != nil {
+		a := *m.autoLimit
+		s.AutoLimit = &a
+	}
+	m.mu.Unlock()
+	return s
+}
+
+func (m *MemoryAPI) APIGetGC(w http.ResponseWriter, _ *http.Request) {
+	apiutils.ServeJSON(w, m.gcSettings())
+}
+
+// APISetGC sets GOGC from the gogc parameter, a percentage or off, and
+// GOMEMLIMIT from memlimit, a size such as 512MiB or off.
+func (m *MemoryAPI) APISetGC(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	gogc, limit := 0, int64(0)
+	var err error
+	if s := q.Get("gogc"); s != "" {
+		if gogc, err = parseGOGC(s); err != nil {
+			http.Error(w, "bad gogc param", http.StatusBadRequest)
+			return
+		}
+	}
+	if s := q.Get("memlimit"); s != "" {
+		if limit, err = parseMemLimit(s); err != nil {
+			http.Error(w, "bad memlimit param", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if q.Has("gogc") {
+		debug.SetGCPercent(gogc)
+	}
+	if q.Has("memlimit") {
+		debug.SetMemoryLimit(limit)
+		m.mu.Lock()
+		m.autoLimit = nil
+		m.mu.Unlock()
+	}
+	apiutils.ServeJSON(w, m.gcSettings())
+}
+
+// APIForceGC runs a collection, and returns freed memory to the OS if the
+// release parameter is true.
+func (m *MemoryAPI) APIForceGC(w http.ResponseWriter, r *http.Request) {
+	release, _ := strconv.ParseBool(r.URL.Query().Get("release"))
+	resp := &GCResult{Before: readGCStats(), Released: release}
+
+	start := time.Now()
+	if release {
+		debug.FreeOSMemory()
+	} else {
+		runtime.GC()
+	}
+	resp.Duration = time.Since(start)
+	resp.After = readGCStats()
+
+	apiutils.ServeJSON(w, resp)
+}
+
+func readGCStats() GCStats {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return GCStats{
+		HeapAlloc:    ms.HeapAlloc,
+		HeapInuse:    ms.HeapInuse,
+		HeapIdle:     ms.HeapIdle,
+		HeapReleased: ms.HeapReleased,
+		HeapObjects:  ms.HeapObjects,
+		Sys:          ms.Sys,
+		NumGC:        ms.NumGC,
+	}
+}
+
+func parseGOGC(s string) (int, error) {
+	if s == "off" {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("bad GOGC %q", s)
+	}
+	return n, nil
+}
+
+// parseMemLimit parses a GOMEMLIMIT value: a byte count with an optional
+// B, KiB, MiB, GiB or TiB suffix, or off.
+func parseMemLimit(s string) (int64, error) {
+	if s == "off" {
+		return math.MaxInt64, nil
+	}
+	num, mult := s, int64(1)
+	for i, suffix := range []string{"TiB", "GiB", "MiB", "KiB", "B"} {
+		if n, ok := strings.CutSuffix(s, suffix); ok {
+			num, mult = n, 1<<(10*(4-i))
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 || n > math.MaxInt64/mult {
+		return 0, fmt.Errorf("bad GOMEMLIMIT %q", s)
+	}
+	return n * mult, nil
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const mib = 1 << 20
+
+// leakInterval is how often the leak allocates its next block. Shortened in
+// tests.
+var leakInterval = time.Second
+
+// Leak is a background leak, growing by RateMB every second until MaxMB.
+type Leak struct {
+	Kind    string    `json:"kind"`
+	RateMB  float64   `json:"rateMB"`
+	MaxMB   float64   `json:"maxMB,omitempty"` // 0 is no ceiling
+	Started time.Time `json:"started"`
+	Bytes   int64     `json:"bytes"` // leaked so far
+	Running bool      `json:"running"`
+	Error   string    `json:"error,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// StartLeak replaces any running leak with l.
+func (m *MemoryAPI) StartLeak(l Leak) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.Started, l.Running, l.cancel = time.Now(), true, cancel
+
+	m.mu.Lock()
+	m.stopLeakLocked()
+	m.leak = &l
+	m.mu.Unlock()
+
+	slog.Info("memory leak started", "kind", l.Kind, "rateMB", l.RateMB, "maxMB", l.MaxMB)
+	go m.runLeak(ctx, &l)
+}
+
+func (m *MemoryAPI) runLeak(ctx context.Context, l *Leak) {
+	t := time.NewTicker(leakInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+
+		size := int64(l.RateMB * mib * leakInterval.Seconds())
+		m.mu.Lock()
+		if l.MaxMB > 0 {
+			size = min(size, int64(l.MaxMB*mib)-l.Bytes)
+		}
+		m.mu.Unlock()
+		if size <= 0 {
+			m.endLeak(l, "")
+			return
+		}
+
+		if limit := m.allocLimit(); size > limit {
+			m.endLeak(l, fmt.Sprintf("leak step of %d bytes exceeds the memory limit of %d bytes", size, limit))
+			return
+		}
+		a, err := allocate(l.Kind, size, m.shmDir)
+		if err != nil {
+			m.endLeak(l, err.Error())
+			return
+		}
+		a.Leak = true
+
+		m.mu.Lock()
+		if ctx.Err() != nil {
+			// Stopped while allocating.
+			m.mu.Unlock()
+			a.free()
+			return
+		}
+		m.addLocked(a)
+		l.Bytes += size
+		m.mu.Unlock()
+	}
+}
+
+func (m *MemoryAPI) endLeak(l *Leak, errMsg string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	l.Running, l.Error = false, errMsg
+	l.cancel()
+	if errMsg != "" {
+		slog.Warn("memory leak stopped", "error", errMsg, "bytes", l.Bytes)
+	} else {
+		slog.Info("memory leak reached its ceiling", "bytes", l.Bytes)
+	}
+}
+
+// StopLeak stops the leak. What it allocated stays held.
+func (m *MemoryAPI) StopLeak() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.stopLeakLocked()
+}
+
+func (m *MemoryAPI) stopLeakLocked() {
+	if m.leak != nil && m.leak.Running {
+		m.leak.cancel()
+		m.leak.Running = false
+	}
+}
@@ -18,12 +18,17 @@ package memqclient
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"path"
 
+	"go.opentelemetry.io/otel/propagation"
+
 	"github.com/kubernetes-up-and-running/kuard/pkg/memq"
+	"github.com/kubernetes-up-and-running/kuard/pkg/tracing"
 )
 
 type Client struct {
@@ -37,56 +42,48 @@ func errorFromResponse(resp *http.Response) error {
 	return nil
 }
 
+// do sends a request carrying the trace context of ctx.
+func (c *Client) do(ctx context.Context, method, url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	tracing.Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return http.DefaultClient.Do(req)
+}
+
 func (c *Client) queueURL(queue string, s ...string) string {
 	s = append([]string{"queues", queue}, s...)
 	tail := path.Join(s...)
 	return fmt.Sprintf("%s/%s", c.BaseServerURL, tail)
 }
 
-func (c *Client) CreateQueue(queue string) error {
-	req, err := http.NewRequest("PUT", c.queueURL(queue), nil)
-	if err != nil {
-		return err
-	}
-	resp, err := http.DefaultClient.Do(req)
+func (c *Client) CreateQueue(ctx context.Context, queue string) error {
+	resp, err := c.do(ctx, "PUT", c.queueURL(queue), nil)
 	if err != nil {
 		return err
 	}
 	return errorFromResponse(resp)
 }
 
-func (c *Client) DeleteQueue(queue string) error {
-	req, err := http.NewRequest("DELETE", c.queueURL(queue), nil)
-	if err != nil {
-		return err
-	}
-	resp, err := http.DefaultClient.Do(req)
+func (c *Client) DeleteQueue(ctx context.Context, queue string) error {
+	resp, err := c.do(ctx, "DELETE", c.queueURL(queue), nil)
 	if err != nil {
 		return err
 	}
 	return errorFromResponse(resp)
 }
 
-func (c *Client) DrainQueue(queue string) error {
-	req, err := http.NewRequest("POST", c.queueURL(queue, "drain"), nil)
-	if err != nil {
-		return err
-	}
-	resp, err := http.DefaultClient.Do(req)
+func (c *Client) DrainQueue(ctx context.Context, queue string) error {
+	resp, err := c.do(ctx, "POST", c.queueURL(queue, "drain"), nil)
 	if err != nil {
 		return err
 	}
 	return errorFromResponse(resp)
 }
 
-func (c *Client) Enqueue(queue, data string) (*memq.Message, error) {
-	req, err := http.NewRequest(
-		"POST", c.queueURL(queue, "enqueue"),
-		bytes.NewBufferString(data))
-	if err != nil {
-		return nil, err
-	}
-	resp, err := http.DefaultClient.Do(req)
+func (c *Client) Enqueue(ctx context.Context, queue, data string) (*memq.Message, error) {
+	resp, err := c.do(ctx, "POST", c.queueURL(queue, "enqueue"), bytes.NewBufferString(data))
 	if err != nil {
 		return nil, err
 	}
@@ -105,12 +102,8 @@ func (c *Client) Enqueue(queue, data string) (*memq.Message, error) {
 
 // Dequeue takes an item off of queue from the server.  If a nil message is
 // returned with no error then the queue is empty.
-func (c *Client) Dequeue(queue string) (*memq.Message, error) {
-	req, err := http.NewRequest("POST", c.queueURL(queue, "dequeue"), nil)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := http.DefaultClient.Do(req)
+func (c *Client) Dequeue(ctx context.Context, queue string) (*memq.Message, error) {
+	resp, err := c.do(ctx, "POST", c.queueURL(queue, "dequeue"), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -130,12 +123,8 @@ func (c *Client) Dequeue(queue string) (*memq.Message, error) {
 	return m, nil
 }
 
-func (c *Client) Stats() (*memq.Stats, error) {
-	req, err := http.NewRequest("GET", c.BaseServerURL+"/stats", nil)
-	if err != nil {
-		return nil, err
-	}
-	resp, err := http.DefaultClient.Do(req)
+func (c *Client) Stats(ctx context.Context) (*memq.Stats, error) {
+	resp, err := c.do(ctx, "GET", c.BaseServerURL+"/stats", nil)
 	if err != nil {
 		return nil, err
 	}
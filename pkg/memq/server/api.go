@@ -21,6 +21,7 @@ import (
 	"net/http"
 
 	"github.com/kubernetes-up-and-running/kuard/pkg/apiutils"
+	"github.com/kubernetes-up-and-running/kuard/pkg/memq"
 	"github.com/kubernetes-up-and-running/kuard/pkg/route"
 )
 
@@ -34,15 +35,35 @@ func NewServer() *Server {
 }
 
 func (s *Server) AddRoutes(router route.Router, base string) {
-	router.GET(base+"/stats", http.HandlerFunc(s.GetStats))
-	router.PUT(base+"/queues", http.HandlerFunc(s.CreateQueue))       // ?queue=name
-	router.DELETE(base+"/queues", http.HandlerFunc(s.DeleteQueue))    // ?queue=name
-	router.POST(base+"/queues/drain", http.HandlerFunc(s.DrainQueue)) // ?queue=name
-	router.POST(base+"/queues/dequeue", http.HandlerFunc(s.Dequeue))  // ?queue=name
-	router.POST(base+"/queues/enqueue", http.HandlerFunc(s.Enqueue))  // ?queue=name
+	router.GET(base+"/stats", describe(s.GetStats, "Stats for all queues", nil, &memq.Stats{}))
+	router.PUT(base+"/queues", describe(s.CreateQueue, "Create a queue", queueQuery, nil))
+	router.DELETE(base+"/queues", describe(s.DeleteQueue, "Delete a queue", queueQuery, nil))
+	router.POST(base+"/queues/drain", describe(s.DrainQueue, "Discard all messages in a queue", queueQuery, nil))
+	router.POST(base+"/queues/dequeue", describe(s.Dequeue, "Dequeue a message (204 if empty)", queueQuery, &memq.Message{}))
+	router.POST(base+"/queues/enqueue", describe(s.Enqueue, "Enqueue the request body", queueQuery, &memq.Message{}))
+
+	// REST style, queue name in the path (used by memqclient).
+	router.PUT(base+"/queues/:name", describe(s.CreateQueue, "Create a queue", nil, nil))
+	router.DELETE(base+"/queues/:name", describe(s.DeleteQueue, "Delete a queue", nil, nil))
+	router.POST(base+"/queues/:name/drain", describe(s.DrainQueue, "Discard all messages in a queue", nil, nil))
+	router.POST(base+"/queues/:name/dequeue", describe(s.Dequeue, "Dequeue a message (204 if empty)", nil, &memq.Message{}))
+	router.POST(base+"/queues/:name/enqueue", describe(s.Enqueue, "Enqueue the request body", nil, &memq.Message{}))
 }
 
-func getQueueParam(r *http.Request) string { return r.URL.Query().Get("queue") }
+var queueQuery = []string{"queue"}
+
+func describe(h http.HandlerFunc, summary string, query []string, resp any) http.Handler {
+	return route.Describe(h, route.Meta{Summary: summary, Query: query, Response: resp})
+}
+
+// getQueueParam returns the queue name from the :name path segment, falling
+// back to the ?queue= query parameter.
+func getQueueParam(r *http.Request) string {
+	if name := route.Param(r, "name"); name != "" {
+		return name
+	}
+	return r.URL.Query().Get("queue")
+}
 
 func (s *Server) CreateQueue(w http.ResponseWriter, r *http.Request) {
 	qName := getQueueParam(r)
@@ -50,7 +71,7 @@ func (s *Server) CreateQueue(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, ErrEmptyName.Error(), http.StatusBadRequest)
 		return
 	}
-	err := s.nb.CreateQueue(qName)
+	err := s.nb.CreateQueue(r.Context(), qName)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 	}
@@ -62,7 +83,7 @@ func (s *Server) DeleteQueue(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, ErrEmptyName.Error(), http.StatusBadRequest)
 		return
 	}
-	err := s.nb.DeleteQueue(qName)
+	err := s.nb.DeleteQueue(r.Context(), qName)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 	}
@@ -74,7 +95,7 @@ func (s *Server) DrainQueue(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, ErrEmptyName.Error(), http.StatusBadRequest)
 		return
 	}
-	err := s.nb.DrainQueue(qName)
+	err := s.nb.DrainQueue(r.Context(), qName)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 	}
@@ -91,7 +112,7 @@ func (s *Server) Enqueue(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, ErrEmptyName.Error(), http.StatusBadRequest)
 		return
 	}
-	msg, err := s.nb.PutMessage(qName, string(body))
+	msg, err := s.nb.PutMessage(r.Context(), qName, string(body))
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -105,7 +126,7 @@ func (s *Server) Dequeue(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, ErrEmptyName.Error(), http.StatusBadRequest)
 		return
 	}
-	m, err := s.nb.GetMessage(qName)
+	m, err := s.nb.GetMessage(r.Context(), qName)
 	if err == ErrEmptyQueue {
 		w.WriteHeader(http.StatusNoContent)
 		return
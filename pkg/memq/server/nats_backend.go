@@ -13,9 +13,35 @@ import (
 	"time"
 
 	"github.com/kubernetes-up-and-running/kuard/pkg/memq"
+	"github.com/kubernetes-up-and-running/kuard/pkg/tracing"
 	"github.com/nats-io/nats.go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/kubernetes-up-and-running/kuard/pkg/memq/server")
+
+// startSpan starts a child span for a queue operation.
+func startSpan(ctx context.Context, op, queue string, kind trace.SpanKind) (context.Context, trace.Span) {
+	return tracer.Start(ctx, "memq "+op, trace.WithSpanKind(kind), trace.WithAttributes(
+		attribute.String("messaging.system", "nats"),
+		attribute.String("messaging.operation.name", op),
+		attribute.String("messaging.destination.name", queue),
+	))
+}
+
+// endSpan records err, if any, and ends span.
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != ErrEmptyQueue {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
 // natsBackend implements queue semantics on top of NATS JetStream.
 // Each queue maps to a JetStream Stream with subject memq.<name>.
 // Dequeue uses a pull consumer created per call (simple but fine for demo scale).
@@ -84,7 +110,9 @@ func uuid() (string, error) {
 func (nb *natsBackend) streamName(q string) string { return "MEMQ_" + strings.ToUpper(q) }
 func (nb *natsBackend) subject(q string) string    { return "memq." + q }
 
-func (nb *natsBackend) CreateQueue(name string) error {
+func (nb *natsBackend) CreateQueue(ctx context.Context, name string) (err error) {
+	_, span := startSpan(ctx, "create", name, trace.SpanKindInternal)
+	defer func() { endSpan(span, err) }()
 	if name == "" {
 		return ErrEmptyName
 	}
@@ -93,7 +121,7 @@ func (nb *natsBackend) CreateQueue(name string) error {
 	if _, ok := nb.queues[name]; ok {
 		return ErrAlreadyExist
 	}
-	_, err := nb.js.AddStream(&nats.StreamConfig{
+	_, err = nb.js.AddStream(&nats.StreamConfig{
 		Name:     nb.streamName(name),
 		Subjects: []string{nb.subject(name)},
 		// MaxMsgsPerSubject -1 means unlimited; keep demo simple.
@@ -110,7 +138,9 @@ func (nb *natsBackend) CreateQueue(name string) error {
 	return nil
 }
 
-func (nb *natsBackend) DeleteQueue(name string) error {
+func (nb *natsBackend) DeleteQueue(ctx context.Context, name string) (err error) {
+	_, span := startSpan(ctx, "delete", name, trace.SpanKindInternal)
+	defer func() { endSpan(span, err) }()
 	if name == "" {
 		return ErrEmptyName
 	}
@@ -126,7 +156,9 @@ func (nb *natsBackend) DeleteQueue(name string) error {
 	return nil
 }
 
-func (nb *natsBackend) DrainQueue(name string) error {
+func (nb *natsBackend) DrainQueue(ctx context.Context, name string) (err error) {
+	_, span := startSpan(ctx, "drain", name, trace.SpanKindInternal)
+	defer func() { endSpan(span, err) }()
 	nb.mu.RLock()
 	qs, ok := nb.queues[name]
 	nb.mu.RUnlock()
@@ -145,22 +177,50 @@ func (nb *natsBackend) DrainQueue(name string) error {
 	return nil
 }
 
-func (nb *natsBackend) PutMessage(queue, body string) (*memq.Message, error) {
+func (nb *natsBackend) PutMessage(ctx context.Context, queue, body string) (_ *memq.Message, err error) {
+	ctx, span := startSpan(ctx, "publish", queue, trace.SpanKindProducer)
+	defer func() { endSpan(span, err) }()
 	nb.mu.RLock()
 	qs, ok := nb.queues[queue]
 	nb.mu.RUnlock()
 	if !ok {
 		return nil, ErrNotExist
 	}
-	if _, err := nb.js.Publish(nb.subject(queue), []byte(body)); err != nil {
+	// Carry the producer span in the NATS headers so consumers can continue it.
+	msg := nats.NewMsg(nb.subject(queue))
+	msg.Data = []byte(body)
+	tracing.Inject(ctx, propagation.HeaderCarrier(msg.Header))
+	if _, err := nb.js.PublishMsg(msg); err != nil {
 		return nil, err
 	}
 	atomic.AddInt64(&qs.enq, 1)
 	// Construct message metadata placeholder (IDs not tracked identically to in-memory version)
-	return newMessage(body)
+	m, err := newMessage(body)
+	if err != nil {
+		return nil, err
+	}
+	m.Trace = traceMap(msg.Header)
+	return m, nil
+}
+
+// traceMap copies the trace context headers from h.
+func traceMap(h nats.Header) map[string]string {
+	carrier := propagation.HeaderCarrier(h)
+	out := propagation.MapCarrier{}
+	for _, k := range otel.GetTextMapPropagator().Fields() {
+		if v := carrier.Get(k); v != "" {
+			out[k] = v
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
 }
 
-func (nb *natsBackend) GetMessage(queue string) (*memq.Message, error) {
+func (nb *natsBackend) GetMessage(ctx context.Context, queue string) (_ *memq.Message, err error) {
+	_, span := startSpan(ctx, "receive", queue, trace.SpanKindConsumer)
+	defer func() { endSpan(span, err) }()
 	nb.mu.RLock()
 	qs, ok := nb.queues[queue]
 	nb.mu.RUnlock()
@@ -179,9 +239,9 @@ func (nb *natsBackend) GetMessage(queue string) (*memq.Message, error) {
 			return nil, err
 		}
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	fetchCtx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
 	defer cancel()
-	msgs, err := sub.Fetch(1, nats.Context(ctx))
+	msgs, err := sub.Fetch(1, nats.Context(fetchCtx))
 	if err != nil {
 		if errors.Is(err, context.DeadlineExceeded) || strings.Contains(err.Error(), "no messages") {
 			return nil, ErrEmptyQueue
@@ -196,6 +256,10 @@ func (nb *natsBackend) GetMessage(queue string) (*memq.Message, error) {
 	_ = m.Ack()
 	atomic.AddInt64(&qs.deq, 1)
 	msg, _ := newMessage(body)
+	msg.Trace = traceMap(m.Header)
+	if producer := trace.SpanContextFromContext(tracing.Extract(context.Background(), propagation.HeaderCarrier(m.Header))); producer.IsValid() {
+		span.AddLink(trace.Link{SpanContext: producer})
+	}
 	return msg, nil
 }
 
@@ -0,0 +1,19 @@
+package memqserver
+
+import (
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestTraceMap(t *testing.T) {
+	if m := traceMap(nats.Header{}); m != nil {
+		t.Fatalf("expected nil for headers without trace context, got %v", m)
+	}
+	h := nats.Header{}
+	h.Set("Traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	m := traceMap(h)
+	if m["traceparent"] != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Fatalf("unexpected trace map %v", m)
+	}
+}
@@ -36,4 +36,8 @@ type Message struct {
 	ID      string    `json:"id"`
 	Body    string    `json:"body"`
 	Created time.Time `json:"creationTimestamp"`
+
+	// Trace is the W3C trace context of the producer (traceparent and
+	// tracestate), so consumers can continue the producer's trace.
+	Trace map[string]string `json:"trace,omitempty"`
 }
@@ -0,0 +1,243 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package openapi builds an OpenAPI 3 document from route registrations.
+// Schemas are derived by reflection from the example request and response
+// values in route.Meta, honoring json struct tags.
+package openapi
+
+import (
+	"encoding"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps a lower case HTTP method to its operation.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string              `json:"summary,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+	// Wildcard marks a *name capture, which unlike an OpenAPI path
+	// parameter may contain slashes.
+	Wildcard bool `json:"x-kuard-wildcard,omitempty"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required,omitempty"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// Build returns a document describing every route that has a summary.
+func Build(title, version string, routes []route.Info) *Document {
+	d := &Document{
+		OpenAPI:    "3.0.3",
+		Info:       Info{Title: title, Version: version},
+		Paths:      map[string]PathItem{},
+		Components: Components{Schemas: map[string]*Schema{}},
+	}
+	for _, rt := range routes {
+		if rt.Summary == "" {
+			continue
+		}
+		p, params := convertPattern(rt.Pattern)
+		op := &Operation{
+			Summary:    rt.Summary,
+			Parameters: params,
+			Responses:  map[string]Response{},
+		}
+		if rt.Subsystem != "" {
+			op.Tags = []string{rt.Subsystem}
+		}
+		for _, q := range rt.Query {
+			op.Parameters = append(op.Parameters, Parameter{Name: q, In: "query", Schema: &Schema{Type: "string"}})
+		}
+		if rt.Request != nil {
+			op.RequestBody = &RequestBody{
+				Required: true,
+				Content:  map[string]MediaType{"application/json": {Schema: d.schemaFor(reflect.TypeOf(rt.Request))}},
+			}
+		}
+		resp := Response{Description: "OK"}
+		if rt.Response != nil {
+			resp.Content = map[string]MediaType{"application/json": {Schema: d.schemaFor(reflect.TypeOf(rt.Response))}}
+		}
+		op.Responses["200"] = resp
+
+		item, ok := d.Paths[p]
+		if !ok {
+			item = PathItem{}
+			d.Paths[p] = item
+		}
+		item[strings.ToLower(rt.Method)] = op
+	}
+	return d
+}
+
+// convertPattern turns /queues/:name into /queues/{name} and returns the
+// matching path parameters. A trailing *name becomes {name} too, marked as a
+// wildcard since OpenAPI has no way to say a parameter spans segments.
+func convertPattern(pattern string) (string, []Parameter) {
+	var params []Parameter
+	segs := strings.Split(pattern, "/")
+	for i, seg := range segs {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			name := seg[1:]
+			segs[i] = "{" + name + "}"
+			p := Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}}
+			if seg[0] == '*' {
+				p.Wildcard = true
+				p.Description = "The rest of the path. It may contain slashes and may be empty."
+			}
+			params = append(params, p)
+		}
+	}
+	return strings.Join(segs, "/"), params
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (d *Document) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	// encoding/json writes these as strings, e.g. apiutils.Duration.
+	if t.Implements(textMarshalerType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64, reflect.Uintptr:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		// encoding/json writes []byte as a base64 string.
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		name := path.Base(t.PkgPath()) + "." + t.Name()
+		if _, ok := d.Components.Schemas[name]; !ok {
+			// Reserve the name first so recursive types terminate.
+			d.Components.Schemas[name] = &Schema{}
+			*d.Components.Schemas[name] = *d.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagName, _, _ := strings.Cut(tag, ",")
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			} else if f.Anonymous {
+				// Embedded structs without a name are flattened, as in encoding/json.
+				name = ""
+			}
+		} else if f.Anonymous {
+			name = ""
+		}
+		if name == "" && f.Type.Kind() == reflect.Struct {
+			for k, v := range d.structSchema(f.Type).Properties {
+				s.Properties[k] = v
+			}
+			continue
+		}
+		s.Properties[name] = d.schemaFor(f.Type)
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package openapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/apiutils"
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+type item struct {
+	Name    string            `json:"name"`
+	Created time.Time         `json:"created"`
+	Skip    string            `json:"-"`
+	Tags    []string          `json:"tags,omitempty"`
+	Data    []byte            `json:"data"`
+	Wait    apiutils.Duration `json:"wait"`
+}
+
+func TestBuild(t *testing.T) {
+	d := Build("test", "v1", []route.Info{
+		{Method: "POST", Pattern: "/queues/:name/enqueue", Meta: route.Meta{Subsystem: "memq", Summary: "enqueue", Response: &item{}}},
+		{Method: "GET", Pattern: "/undocumented"},
+	})
+	if _, ok := d.Paths["/undocumented"]; ok {
+		t.Fatalf("routes without a summary should be omitted")
+	}
+	op := d.Paths["/queues/{name}/enqueue"]["post"]
+	if op == nil {
+		t.Fatalf("missing operation: %+v", d.Paths)
+	}
+	if len(op.Parameters) != 1 || op.Parameters[0].In != "path" || op.Parameters[0].Name != "name" {
+		t.Fatalf("unexpected parameters %+v", op.Parameters)
+	}
+	if ref := op.Responses["200"].Content["application/json"].Schema.Ref; ref != "#/components/schemas/openapi.item" {
+		t.Fatalf("unexpected ref %q", ref)
+	}
+	s := d.Components.Schemas["openapi.item"]
+	if s == nil || s.Properties["created"].Format != "date-time" || s.Properties["tags"].Type != "array" {
+		t.Fatalf("unexpected schema %+v", s)
+	}
+	if _, ok := s.Properties["Skip"]; ok {
+		t.Fatalf("json:\"-\" field should be skipped")
+	}
+	if data := s.Properties["data"]; data.Type != "string" || data.Format != "byte" {
+		t.Fatalf("[]byte should be a base64 string, got %+v", data)
+	}
+	if wait := s.Properties["wait"]; wait.Type != "string" {
+		t.Fatalf("text marshalers should be strings, got %+v", wait)
+	}
+}
+
+func TestBuildWildcard(t *testing.T) {
+	d := Build("test", "v1", []route.Info{
+		{Method: "GET", Pattern: "/fs/*filepath", Meta: route.Meta{Summary: "files"}},
+	})
+	op := d.Paths["/fs/{filepath}"]["get"]
+	if op == nil {
+		t.Fatalf("missing operation: %+v", d.Paths)
+	}
+	if p := op.Parameters[0]; !p.Wildcard || p.Description == "" {
+		t.Fatalf("catch-all parameter not marked as a wildcard: %+v", p)
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package podinfo reports the identity of the pod kuard runs in, from
+// downward API environment variables and the labels and annotations files
+// of a downward API volume. The files are watched so label and annotation
+// changes show up without a restart.
+package podinfo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/dirwatch"
+)
+
+// Environment variables read for pod identity. Set them from the downward
+// API, e.g. POD_NAME from fieldRef metadata.name.
+const (
+	EnvName           = "POD_NAME"
+	EnvNamespace      = "POD_NAMESPACE"
+	EnvNodeName       = "NODE_NAME"
+	EnvServiceAccount = "POD_SERVICE_ACCOUNT"
+)
+
+// File names read from the downward API volume.
+const (
+	LabelsFile      = "labels"
+	AnnotationsFile = "annotations"
+)
+
+// namespaceFile is mounted in every pod with a service account token.
+const namespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Info is what is known about the pod.
+type Info struct {
+	Name           string            `json:"name,omitempty"`
+	Namespace      string            `json:"namespace,omitempty"`
+	NodeName       string            `json:"nodeName,omitempty"`
+	ServiceAccount string            `json:"serviceAccount,omitempty"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Annotations    map[string]string `json:"annotations,omitempty"`
+}
+
+// Empty reports whether nothing is known, e.g. when not running in a pod.
+func (i *Info) Empty() bool {
+	return i.Name == "" && i.Namespace == "" && i.NodeName == "" && i.ServiceAccount == "" &&
+		len(i.Labels) == 0 && len(i.Annotations) == 0
+}
+
+// Source holds the current pod info for a downward API directory.
+type Source struct {
+	dir string
+
+	mu   sync.RWMutex
+	info Info
+}
+
+func New(dir string) *Source {
+	return &Source{dir: dir}
+}
+
+// Load reads the environment and the label and annotation files. Missing
+// files are not an error; on a parse error the current info is kept.
+func (s *Source) Load() error {
+	info := Info{
+		Name:           os.Getenv(EnvName),
+		Namespace:      os.Getenv(EnvNamespace),
+		NodeName:       os.Getenv(EnvNodeName),
+		ServiceAccount: os.Getenv(EnvServiceAccount),
+	}
+	if info.Namespace == "" {
+		if b, err := os.ReadFile(namespaceFile); err == nil {
+			info.Namespace = strings.TrimSpace(string(b))
+		}
+	}
+
+	var err error
+	if info.Labels, err = readFile(filepath.Join(s.dir, LabelsFile)); err != nil {
+		return err
+	}
+	if info.Annotations, err = readFile(filepath.Join(s.dir, AnnotationsFile)); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.info = info
+	s.mu.Unlock()
+	return nil
+}
+
+// Info returns a copy of the current pod info.
+func (s *Source) Info() Info {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i := s.info
+	i.Labels = maps.Clone(i.Labels)
+	i.Annotations = maps.Clone(i.Annotations)
+	return i
+}
+
+// Watch reloads whenever the directory changes, until ctx is cancelled.
+func (s *Source) Watch(ctx context.Context) error {
+	return dirwatch.Watch(ctx, s.dir, func() {
+		if err := s.Load(); err != nil {
+			slog.Warn("podinfo reload failed; keeping previous", "dir", s.dir, "error", err)
+			return
+		}
+		slog.Info("podinfo reloaded", "dir", s.dir)
+	})
+}
+
+// readFile parses the downward API format, one key="quoted value" per line.
+// A missing file yields no entries.
+func readFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	m, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+func parse(r io.Reader) (map[string]string, error) {
+	out := map[string]string{}
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<20) // annotations such as last-applied-configuration are long
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("malformed line %q", line)
+		}
+		uv, err := strconv.Unquote(v)
+		if err != nil {
+			return nil, fmt.Errorf("malformed value for %s", k)
+		}
+		out[k] = uv
+	}
+	return out, sc.Err()
+}
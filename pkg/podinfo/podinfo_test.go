@@ -0,0 +1,67 @@
+package podinfo
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/dirwatch"
+)
+
+func TestParse(t *testing.T) {
+	m, err := parse(strings.NewReader("app=\"kuard\"\nnote=\"a \\\"quoted\\\" value\\nline two\"\n\n"))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if m["app"] != "kuard" || m["note"] != "a \"quoted\" value\nline two" {
+		t.Fatalf("unexpected %v", m)
+	}
+	if _, err := parse(strings.NewReader("app=kuard\n")); err == nil {
+		t.Fatalf("expected error for unquoted value")
+	}
+}
+
+func TestLoadAndWatch(t *testing.T) {
+	t.Setenv(EnvName, "kuard-abc")
+	t.Setenv(EnvNamespace, "demo")
+	dir := t.TempDir()
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	write(LabelsFile, "app=\"kuard\"\n")
+
+	s := New(dir)
+	if err := s.Load(); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	info := s.Info()
+	if info.Name != "kuard-abc" || info.Namespace != "demo" || info.Labels["app"] != "kuard" || info.Annotations != nil {
+		t.Fatalf("unexpected info %+v", info)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Watch(ctx)
+	time.Sleep(50 * time.Millisecond)
+	write(LabelsFile, "app=\"kuard\"\ntrack=\"canary\"\n")
+
+	deadline := time.Now().Add(3 * time.Second)
+	for s.Info().Labels["track"] != "canary" {
+		if time.Now().After(deadline) {
+			t.Fatalf("label change not picked up: %+v", s.Info())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// A malformed update keeps the previous labels.
+	write(LabelsFile, "broken\n")
+	time.Sleep(dirwatch.Debounce + 200*time.Millisecond)
+	if s.Info().Labels["track"] != "canary" {
+		t.Fatalf("malformed update applied: %+v", s.Info())
+	}
+}
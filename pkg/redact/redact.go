@@ -0,0 +1,95 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package redact hides secrets in settings before they are logged or served.
+// A value is treated as secret when its key names one (password, token, ...)
+// or when the value itself looks like one: a PEM block, a bearer token, a JWT,
+// an AWS access key ID, or a URL with a password in it.
+package redact
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Mask replaces redacted values.
+const Mask = "[redacted]"
+
+var secretKey = regexp.MustCompile(`(?i)(password|passwd|secret|token|credential|api[-_]?key|private[-_]?key|access[-_]?key)`)
+
+var (
+	jwt          = regexp.MustCompile(`^eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*$`)
+	awsAccessKey = regexp.MustCompile(`\b(AKIA|ASIA)[A-Z0-9]{16}\b`)
+)
+
+// LooksSecret reports whether s itself looks like a credential, whatever it
+// is called. URLs with passwords are not included; Value redacts just the
+// password in those.
+func LooksSecret(s string) bool {
+	return strings.Contains(s, "-----BEGIN ") ||
+		strings.HasPrefix(strings.ToLower(s), "bearer ") ||
+		jwt.MatchString(s) ||
+		awsAccessKey.MatchString(s)
+}
+
+// IsSecretKey reports whether key names a secret.
+func IsSecretKey(key string) bool {
+	return secretKey.MatchString(key)
+}
+
+// Value returns v, or a redacted form of it if key or v looks secret. Empty
+// values are returned as is so it stays visible that nothing is set.
+func Value(key string, v any) any {
+	s, isString := v.(string)
+	if v == nil || (isString && s == "") {
+		return v
+	}
+	if IsSecretKey(key) {
+		return Mask
+	}
+	if !isString {
+		return v
+	}
+	if LooksSecret(s) {
+		return Mask
+	}
+	if u, err := url.Parse(s); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			return u.Redacted()
+		}
+	}
+	return v
+}
+
+// Map returns a copy of settings, nested as from viper's AllSettings, with
+// secrets redacted. Nested values are checked against their dotted key.
+func Map(settings map[string]any) map[string]any {
+	return redactMap("", settings)
+}
+
+func redactMap(prefix string, m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		key := prefix + k
+		if sub, ok := v.(map[string]any); ok && !IsSecretKey(key) {
+			out[k] = redactMap(key+".", sub)
+			continue
+		}
+		out[k] = Value(key, v)
+	}
+	return out
+}
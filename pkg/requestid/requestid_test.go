@@ -0,0 +1,43 @@
+package requestid
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewarePropagatesAndGenerates(t *testing.T) {
+	var seen string
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = FromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(Header, "abc-123")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if seen != "abc-123" || w.Header().Get(Header) != "abc-123" {
+		t.Fatalf("expected propagated id, got ctx=%q header=%q", seen, w.Header().Get(Header))
+	}
+
+	// Missing or unsafe IDs are replaced.
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(Header, "bad id\n")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if seen == "" || seen == "bad id\n" || w.Header().Get(Header) != seen {
+		t.Fatalf("expected generated id, got ctx=%q header=%q", seen, w.Header().Get(Header))
+	}
+}
+
+func TestLogHandler(t *testing.T) {
+	var buf bytes.Buffer
+	log := slog.New(NewLogHandler(slog.NewTextHandler(&buf, nil)))
+	log.InfoContext(NewContext(t.Context(), "xyz"), "hello")
+	if !strings.Contains(buf.String(), "request_id=xyz") {
+		t.Fatalf("missing request_id in %q", buf.String())
+	}
+}
@@ -1,11 +1,183 @@
 package route
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 // Router defines minimal methods needed for registering endpoints.
 type Router interface {
 	GET(pattern string, h http.Handler)
 	POST(pattern string, h http.Handler)
 	PUT(pattern string, h http.Handler)
+	PATCH(pattern string, h http.Handler)
 	DELETE(pattern string, h http.Handler)
 }
+
+// Params holds the values captured from :name and *name pattern segments.
+type Params map[string]string
+
+type paramsKey struct{}
+
+// WithParams returns a copy of ctx carrying the captured path parameters.
+func WithParams(ctx context.Context, p Params) context.Context {
+	return context.WithValue(ctx, paramsKey{}, p)
+}
+
+// ParamsFromContext returns the path parameters stored in ctx, if any.
+func ParamsFromContext(ctx context.Context) Params {
+	p, _ := ctx.Value(paramsKey{}).(Params)
+	return p
+}
+
+// Param returns the named path parameter captured for r, or "" if the
+// matched pattern had no such segment.
+func Param(r *http.Request, name string) string {
+	return ParamsFromContext(r.Context())[name]
+}
+
+// Meta is optional metadata attached to a registration. It drives the /routes
+// listing and the generated OpenAPI document.
+type Meta struct {
+	// Subsystem is the component that owns the route, e.g. "mem" or "keygen".
+	Subsystem string
+	// Summary is a one line description. Only routes with a summary are
+	// included in the OpenAPI document.
+	Summary string
+	// Query lists the query parameters the handler understands.
+	Query []string
+	// Request and Response are example values whose types describe the JSON
+	// request and response bodies. Either may be nil.
+	Request  any
+	Response any
+	// ReadOnly marks a route that changes nothing although its method
+	// usually would, such as a lookup taking a POST body.
+	ReadOnly bool
+}
+
+// Info describes a single registration.
+type Info struct {
+	Method  string
+	Pattern string
+	Meta
+}
+
+type describedHandler struct {
+	http.Handler
+	meta Meta
+}
+
+// Describe attaches m to h. Fields left empty in m are inherited from any
+// metadata already attached to h.
+func Describe(h http.Handler, m Meta) http.Handler {
+	if d, ok := h.(*describedHandler); ok {
+		if m.Subsystem == "" {
+			m.Subsystem = d.meta.Subsystem
+		}
+		if m.Summary == "" {
+			m.Summary = d.meta.Summary
+		}
+		if m.Query == nil {
+			m.Query = d.meta.Query
+		}
+		if m.Request == nil {
+			m.Request = d.meta.Request
+		}
+		if m.Response == nil {
+			m.Response = d.meta.Response
+		}
+		m.ReadOnly = m.ReadOnly || d.meta.ReadOnly
+		h = d.Handler
+	}
+	return &describedHandler{Handler: h, meta: m}
+}
+
+// MetaOf returns the metadata attached to h with Describe.
+func MetaOf(h http.Handler) (Meta, bool) {
+	d, ok := h.(*describedHandler)
+	if !ok {
+		return Meta{}, false
+	}
+	return d.meta, true
+}
+
+// WithSubsystem returns a Router that tags every registration made through it
+// as owned by the named subsystem before passing it on to r.
+func WithSubsystem(r Router, name string) Router {
+	return Wrap(r, func(_, _ string, h http.Handler) http.Handler {
+		m, _ := MetaOf(h)
+		if m.Subsystem == "" {
+			m.Subsystem = name
+		}
+		return Describe(h, m)
+	})
+}
+
+// Wrap returns a Router that passes every registration made through it to
+// wrap, then registers the result with r. Metadata attached to the original
+// handler is kept unless wrap attaches its own.
+func Wrap(r Router, wrap func(method, pattern string, h http.Handler) http.Handler) Router {
+	return &wrapRouter{r: r, wrap: wrap}
+}
+
+type wrapRouter struct {
+	r    Router
+	wrap func(method, pattern string, h http.Handler) http.Handler
+}
+
+func (wr *wrapRouter) handler(method, pattern string, h http.Handler) http.Handler {
+	out := wr.wrap(method, pattern, h)
+	if _, ok := MetaOf(out); !ok {
+		if m, ok := MetaOf(h); ok {
+			out = Describe(out, m)
+		}
+	}
+	return out
+}
+
+func (wr *wrapRouter) GET(pattern string, h http.Handler) {
+	wr.r.GET(pattern, wr.handler(http.MethodGet, pattern, h))
+}
+func (wr *wrapRouter) POST(pattern string, h http.Handler) {
+	wr.r.POST(pattern, wr.handler(http.MethodPost, pattern, h))
+}
+func (wr *wrapRouter) PUT(pattern string, h http.Handler) {
+	wr.r.PUT(pattern, wr.handler(http.MethodPut, pattern, h))
+}
+func (wr *wrapRouter) PATCH(pattern string, h http.Handler) {
+	wr.r.PATCH(pattern, wr.handler(http.MethodPatch, pattern, h))
+}
+func (wr *wrapRouter) DELETE(pattern string, h http.Handler) {
+	wr.r.DELETE(pattern, wr.handler(http.MethodDelete, pattern, h))
+}
+
+// Match is filled in by the router with the pattern that served a request. It
+// lets middleware outside the router label requests by route template rather
+// than raw path.
+type Match struct {
+	Pattern string
+}
+
+type matchKey struct{}
+
+// WithMatch returns a copy of ctx carrying an empty Match for the router to
+// fill in.
+func WithMatch(ctx context.Context) (context.Context, *Match) {
+	m := &Match{}
+	return context.WithValue(ctx, matchKey{}, m), m
+}
+
+// MatchFromContext returns the Match stored in ctx, or nil.
+func MatchFromContext(ctx context.Context) *Match {
+	m, _ := ctx.Value(matchKey{}).(*Match)
+	return m
+}
+
+// EnsureMatch returns ctx and its Match, adding one if ctx has none. Several
+// middlewares can then share the pattern recorded by a single router.
+func EnsureMatch(ctx context.Context) (context.Context, *Match) {
+	if m := MatchFromContext(ctx); m != nil {
+		return ctx, m
+	}
+	return WithMatch(ctx)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package tracing
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// Exporters accepted by Config.Exporter.
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// Config controls span export.
+type Config struct {
+	// Exporter is one of none, otlp (OTLP/HTTP) or stdout.
+	Exporter string `json:"exporter"`
+
+	// Endpoint is the OTLP/HTTP collector URL, e.g.
+	// http://otel-collector:4318. If empty the standard OTEL_EXPORTER_OTLP_*
+	// environment variables apply.
+	Endpoint string `json:"endpoint"`
+
+	// SampleRatio is the fraction of new traces to sample. Incoming sampled
+	// traces are always continued.
+	SampleRatio float64 `json:"sampleRatio" mapstructure:"sample-ratio"`
+}
+
+func BindConfig(v *viper.Viper, fs *pflag.FlagSet) {
+	fs.String("tracing-exporter", ExporterNone, "Trace exporter: none, otlp (OTLP/HTTP) or stdout")
+	fs.String("tracing-endpoint", "", "OTLP/HTTP endpoint URL. Defaults to the OTEL_EXPORTER_OTLP_* environment variables.")
+	fs.Float64("tracing-sample-ratio", 1, "Fraction of new traces to sample (0-1)")
+
+	fs.VisitAll(func(f *pflag.Flag) {
+		name := strings.TrimPrefix(f.Name, "tracing-")
+		if name != f.Name {
+			v.BindPFlag("tracing."+name, f)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2017 The KUAR Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package tracing wires up OpenTelemetry. It installs a W3C trace context
+// propagator, exports spans to OTLP/HTTP or stdout, and provides an HTTP
+// middleware that continues incoming traces.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/felixge/httpsnoop"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+	"github.com/kubernetes-up-and-running/kuard/pkg/version"
+)
+
+const tracerName = "github.com/kubernetes-up-and-running/kuard/pkg/tracing"
+
+func init() {
+	// Propagate W3C trace context even when export is disabled so kuard never
+	// breaks a trace passing through it.
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+}
+
+// Setup installs a global tracer provider for c and returns a function that
+// flushes and stops it.
+func Setup(ctx context.Context, c Config) (func(context.Context) error, error) {
+	var exp sdktrace.SpanExporter
+	var err error
+	switch c.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		var opts []otlptracehttp.Option
+		if c.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpointURL(c.Endpoint))
+		}
+		exp, err = otlptracehttp.New(ctx, opts...)
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", c.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "kuard"),
+		attribute.String("service.version", version.VERSION),
+		attribute.String("host.name", hostname),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(c.SampleRatio))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// TraceID returns the hex trace ID of the span in ctx, or "".
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
+
+// Inject writes the trace context of ctx into carrier.
+func Inject(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
+
+// Extract returns ctx extended with the trace context found in carrier.
+func Extract(ctx context.Context, carrier propagation.TextMapCarrier) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, carrier)
+}
+
+// Middleware starts a server span for every request, continuing any trace
+// in the incoming traceparent header. The span is named after the route
+// pattern the router matched.
+func Middleware(h http.Handler) http.Handler {
+	tracer := otel.Tracer(tracerName)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", r.Method),
+				attribute.String("url.path", r.URL.Path),
+				attribute.String("network.protocol.version", r.Proto),
+				attribute.String("client.address", r.RemoteAddr),
+				attribute.String("user_agent.original", r.UserAgent()),
+			))
+		defer span.End()
+
+		ctx, match := route.EnsureMatch(ctx)
+		m := httpsnoop.CaptureMetrics(h, w, r.WithContext(ctx))
+
+		if match.Pattern != "" {
+			span.SetName(r.Method + " " + match.Pattern)
+			span.SetAttributes(attribute.String("http.route", match.Pattern))
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", m.Code))
+		if m.Code >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(m.Code))
+		}
+	})
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/kubernetes-up-and-running/kuard/pkg/route"
+)
+
+func TestMiddlewareContinuesTrace(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+
+	var seen string
+	h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Stand in for the router recording the matched pattern.
+		route.MatchFromContext(r.Context()).Pattern = "/queues/:name"
+		seen = TraceID(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/queues/work", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if seen != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("trace not continued, got %q", seen)
+	}
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 span got %d", len(spans))
+	}
+	if spans[0].Name() != "GET /queues/:name" {
+		t.Fatalf("unexpected span name %q", spans[0].Name())
+	}
+	if spans[0].Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected parent %s", spans[0].Parent().SpanID())
+	}
+}